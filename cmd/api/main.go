@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,10 +14,21 @@ import (
 	"time"
 
 	"github.com/Belixk/CommerceTwo/config"
+	"github.com/Belixk/CommerceTwo/internal/cache"
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
+	"github.com/Belixk/CommerceTwo/internal/database"
+	"github.com/Belixk/CommerceTwo/internal/events"
+	"github.com/Belixk/CommerceTwo/internal/grpcapi"
 	"github.com/Belixk/CommerceTwo/internal/handlers"
+	"github.com/Belixk/CommerceTwo/internal/health"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/metrics"
 	"github.com/Belixk/CommerceTwo/internal/pkg/hash"
+	"github.com/Belixk/CommerceTwo/internal/pkg/token"
+	"github.com/Belixk/CommerceTwo/internal/policy"
 	"github.com/Belixk/CommerceTwo/internal/repositories"
 	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/Belixk/CommerceTwo/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
@@ -26,64 +40,189 @@ import (
 
 func main() {
 	cfg := config.Load()
-	var db *sqlx.DB
-	var err error
-	// 5 попыток подключения к бд
-	for i := 0; i < 5; i++ {
-		log.Printf("Attemptin to connect to PostgreSQL #%d\n", i+1)
-		db, err = sqlx.Connect("postgres", cfg.GetDBDSN())
-		if err == nil {
-			break
-		}
-		log.Printf("Postgres not ready yet: %v\n", err)
-		time.Sleep(2 * time.Second)
+
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Failed to configure logger: %v", err)
+	}
+	// Сторонние библиотеки и оставшийся стандартный log пишут в тот же поток
+	slog.SetDefault(logger)
+
+	if err := cfg.Validate(); err != nil {
+		fatal(logger, "Invalid configuration", err)
+	}
+
+	traceExporter, closeTraceOutput, err := tracing.NewExporter(context.Background(), cfg.TraceExporter, cfg.TraceFile, cfg.TraceOTLPEndpoint)
+	if err != nil {
+		fatal(logger, "Failed to configure tracing", err)
 	}
+	tracerProvider := tracing.NewProvider(traceExporter)
 
+	db, err := database.NewPostgres(context.Background(), cfg.Database(), logger)
 	if err != nil {
-		log.Fatalf("Postgres connection failed after 5 attempts: %v", err)
+		fatal(logger, "Failed to connect to PostgreSQL", err)
 	}
-	log.Println("Successfully connected to PostgreSQL")
+	logger.Info("Successfully connected to PostgreSQL")
 	defer db.Close()
 
-	runMigrations(db)
+	schemaVersion := runMigrations(logger, db)
 
-	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         cfg.RedisAddr,
+		DialTimeout:  cfg.RedisTimeout,
+		ReadTimeout:  cfg.RedisTimeout,
+		WriteTimeout: cfg.RedisTimeout,
+	})
+	rdb.AddHook(tracing.RedisHook{})
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	// Redis для нас кеш: без него стартуем и работаем через Postgres, клиент переподключится сам
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		logger.Warn("Redis is unavailable, starting without cache", slog.Any("error", err))
+	}
+
+	accessPolicy, err := policy.Load(ctx, repositories.NewRoleRepository(db))
+	if err != nil {
+		fatal(logger, "Failed to load role permissions", err)
+	}
+
+	appMetrics := metrics.New()
+	appMetrics.RegisterDBStats(db.DB)
+
+	// Пока Redis лежит, breaker не даёт каждому запросу ждать его таймаут
+	cacheBreaker := cache.NewBreaker(logger, uint32(cfg.CacheBreakerFailures), cfg.CacheBreakerTimeout)
+	cacheCodec, err := codec.New(cfg.CacheCodec, cfg.CacheCompressThreshold)
+	if err != nil {
+		fatal(logger, "Invalid cache codec", err)
+	}
+	userCache := cache.ProtectUserCache(metrics.InstrumentUserCache(repositories.NewUserCache(rdb, cacheCodec), appMetrics), cacheBreaker)
+	orderCache := cache.ProtectOrderCache(metrics.InstrumentOrderCache(repositories.NewOrderCache(rdb, cacheCodec), appMetrics), cacheBreaker)
+
+	// Кеш в памяти перед Redis, реплики сбрасывают его друг у друга через pub/sub
+	cacheCtx, stopCacheBus := context.WithCancel(context.Background())
+	defer stopCacheBus()
+	if cfg.LocalCacheSize > 0 {
+		bus := cache.NewBus(rdb, cacheBreaker, logger)
+		userCache = cache.NewUserCache(userCache, bus, cfg.LocalCacheSize, cfg.LocalCacheTTL)
+		orderCache = cache.NewOrderCache(orderCache, bus, cfg.LocalCacheSize, cfg.LocalCacheTTL)
+		go bus.Run(cacheCtx)
 	}
 
-	userRepo := repositories.NewUserRepository(db)
-	userCache := repositories.NewUserCache(rdb)
+	userRepo := metrics.InstrumentUserRepository(tracing.InstrumentUserRepository(repositories.NewUserRepository(db)), appMetrics)
 	hasher := &hash.BcryptHasher{}
-	userService := services.NewUserService(userRepo, userCache, hasher)
+	userService := services.NewUserService(userRepo, userCache, orderCache, hasher, accessPolicy)
 	userHandler := handlers.NewUserHandler(userService)
 
-	orderRepo := repositories.NewOrderRepository(db)
-	orderCache := repositories.NewOrderCache(rdb)
+	orderRepo := metrics.InstrumentOrderRepository(tracing.InstrumentOrderRepository(repositories.NewOrderRepository(db)), appMetrics)
+
+	productRepo := repositories.NewProductRepository(db)
+	productService := services.NewProductService(productRepo, accessPolicy)
+	productHandler := handlers.NewProductHandler(productService)
 
-	orderService := services.NewOrderService(orderRepo, orderCache)
+	orderService := services.NewOrderService(orderRepo, productRepo, orderCache, accessPolicy)
 	orderHandler := handlers.NewOrderHandler(orderService)
 
-	r := gin.Default()
+	// Остальное в Redis тоже за breaker: без Redis идемпотентность и refresh токены пропускаются,
+	// корзина сразу отвечает 503
+	idempotencyStore := cache.ProtectIdempotencyStore(repositories.NewIdempotencyStore(rdb), cacheBreaker)
+
+	cartService := services.NewCartService(cache.ProtectCartStore(repositories.NewCartStore(rdb), cacheBreaker), productRepo, orderService, cfg.CartTTL)
+	cartHandler := handlers.NewCartHandler(cartService)
+
+	tokenManager := token.NewJWTManager(cfg.JWTSecret, cfg.AccessTokenTTL)
+	refreshStore := cache.ProtectRefreshTokenStore(repositories.NewRefreshTokenStore(rdb), cacheBreaker)
+	authService := services.NewAuthService(userRepo, hasher, tokenManager, refreshStore, cfg.RefreshTokenTTL, cartService)
+	authHandler := handlers.NewAuthHandler(authService)
+
+	// Relay живёт до начала остановки сервера, неотправленное доберёт следующий запуск
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	publisher, closePublisher := newEventPublisher(logger, cfg)
+	defer closePublisher()
+	relay := events.NewRelay(
+		repositories.NewOutboxRepository(db),
+		publisher,
+		logger,
+		cfg.OutboxInterval,
+		cfg.OutboxBatchSize,
+		cfg.OutboxMaxAttempts,
+	)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("postgres", health.Postgres(db.DB))
+	checker.AddOptional("redis", health.Redis(rdb))
+	checker.AddOptional("cache", cacheBreaker.Check)
+	checker.Add("migrations", health.Migrations(db.DB, schemaVersion))
+	healthHandler := handlers.NewHealthHandler(checker)
+
+	r := gin.New()
+	// Пробы регистрируем до middleware: балансировщик дёргает их постоянно, в логах и метриках они не нужны
+	r.GET("/healthz", healthHandler.Live)
+	r.GET("/readyz", healthHandler.Ready)
+	r.Use(tracing.GinMiddleware(), handlers.RequestLogger(logger), appMetrics.GinMiddleware(), handlers.Recovery())
 
 	v1 := r.Group("/api/v1")
 	{
-		users := v1.Group("/users")
+		auth := v1.Group("/auth")
+		{
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+		}
+		// Регистрация остаётся открытой, всё остальное только с токеном
+		v1.POST("/users/", userHandler.CreateUser)
+		// Каталог смотрят без авторизации, с токеном менеджер каталога видит и снятые с продажи товары
+		v1.GET("/products/", productHandler.ListProducts)
+		v1.GET("/products/:id", handlers.OptionalAuthMiddleware(tokenManager), productHandler.GetProduct)
+
+		// Корзиной пользуются и анонимы, оформление только после входа
+		cart := v1.Group("/cart", handlers.OptionalAuthMiddleware(tokenManager))
+		{
+			cart.GET("/", cartHandler.GetCart)
+			cart.POST("/items", cartHandler.AddItem)
+			cart.PUT("/items/:product_id", cartHandler.UpdateItem)
+			cart.DELETE("/items/:product_id", cartHandler.RemoveItem)
+			cart.POST("/checkout", handlers.AuthMiddleware(tokenManager), cartHandler.Checkout)
+		}
+
+		authorized := v1.Group("/", handlers.AuthMiddleware(tokenManager))
+
+		users := authorized.Group("/users")
 		{
-			users.POST("/", userHandler.CreateUser)
 			users.GET("/:id", userHandler.GetUser)
 			users.PUT("/:id", userHandler.UpdateUser)
 			users.DELETE("/:id", userHandler.DeleteUser)
 		}
-		orders := v1.Group("/orders")
+		orders := authorized.Group("/orders")
 		{
-			orders.POST("/", orderHandler.CreateOrder)
+			orders.POST("/", handlers.IdempotencyMiddleware(idempotencyStore, cfg.IdempotencyTTL), orderHandler.CreateOrder)
+			orders.GET("/", orderHandler.ListOrders)
 			orders.GET("/:id", orderHandler.GetOrderByID)
 			orders.GET("/user/:user_id", orderHandler.GetOrdersByUserID)
 			orders.PUT("/:id", orderHandler.UpdateOrder)
+			orders.PATCH("/:id", orderHandler.PatchOrder)
 			orders.DELETE("/:id", orderHandler.DeleteOrder)
+			orders.POST("/:id/transitions", orderHandler.TransitionOrder)
+			orders.GET("/:id/transitions", orderHandler.GetStatusHistory)
+		}
+		products := authorized.Group("/products")
+		{
+			products.POST("/", productHandler.CreateProduct)
+			products.PUT("/:id", productHandler.UpdateProduct)
+			products.POST("/:id/stock", productHandler.AdjustStock)
+			products.DELETE("/:id", productHandler.DeleteProduct)
+		}
+		// Права проверяет политика в сервисах, здесь только группировка
+		admin := authorized.Group("/admin")
+		{
+			admin.GET("/users", userHandler.ListUsers)
+			admin.PUT("/users/:id/role", userHandler.SetRole)
+			admin.GET("/products", productHandler.ListAllProducts)
 		}
 	}
 
@@ -94,50 +233,141 @@ func main() {
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("listen: %s\n", err)
+			fatal(logger, "HTTP server failed", err)
+		}
+	}()
+	logger.Info("Server starting", slog.String("addr", ":"+cfg.AppPort))
+
+	// Метрики на отдельном порту, чтобы их не было видно снаружи вместе с API
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/metrics", appMetrics.Handler())
+	adminMux.Handle("/debug/db", database.StatsHandler(db))
+	adminSrv := &http.Server{
+		Addr:    ":" + cfg.AdminPort,
+		Handler: adminMux,
+	}
+	go func() {
+		if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "Admin server failed", err)
+		}
+	}()
+	logger.Info("Admin server starting", slog.String("addr", ":"+cfg.AdminPort))
+
+	grpcServer := grpcapi.NewServer(logger, tokenManager, userService, orderService)
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		fatal(logger, "gRPC listen failed", err)
+	}
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			fatal(logger, "gRPC server failed", err)
 		}
 	}()
-	log.Printf("Server starting on :%s", cfg.AppPort)
+	logger.Info("gRPC server starting", slog.String("addr", ":"+cfg.GRPCPort))
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
-	log.Println("Shutting down server...")
+	// Сначала отвечаем not ready и ждём, пока балансировщик уберёт нас из ротации
+	logger.Info("Draining traffic", slog.Duration("delay", cfg.ShutdownDrainDelay))
+	checker.Drain()
+	time.Sleep(cfg.ShutdownDrainDelay)
+	logger.Info("Shutting down server")
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
+	// gRPC останавливаем параллельно с HTTP, если не успел за таймаут, рвём соединения
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		grpcServer.GracefulStop()
+	}()
+
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Fatal("Server forced to Shutdown:", err)
+		fatal(logger, "Server forced to shutdown", err)
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		logger.Warn("gRPC server forced to stop")
+		grpcServer.Stop()
+		<-grpcStopped
 	}
-	log.Println("Closing database connections...")
+	if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Admin server forced to shutdown", slog.Any("error", err))
+	}
+	stopRelay()
+	<-relayDone
+	stopCacheBus()
+	// Дописываем накопленные spans до закрытия файла или соединения с коллектором
+	if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Failed to flush traces", slog.Any("error", err))
+	}
+	if err := closeTraceOutput(); err != nil {
+		logger.Warn("Failed to close trace file", slog.Any("error", err))
+	}
+	logger.Info("Closing database connections")
 	db.Close()
 	rdb.Close()
 
-	log.Println("Server exiting")
+	logger.Info("Server exiting")
+}
+
+// newEventPublisher выбирает, куда relay отправляет события
+func newEventPublisher(logger *slog.Logger, cfg *config.Config) (events.EventPublisher, func()) {
+	switch cfg.EventPublisher {
+	case "kafka":
+		writer := events.NewKafkaWriter(cfg.KafkaBrokers, cfg.KafkaWriteTimeout)
+		publisher, err := events.NewKafkaPublisher(writer, cfg.KafkaTopicPrefix, events.Encoding(cfg.KafkaEncoding))
+		if err != nil {
+			fatal(logger, "Failed to create Kafka publisher", err)
+		}
+		logger.Info("Publishing events to Kafka", slog.Any("brokers", cfg.KafkaBrokers))
+		return publisher, func() { _ = publisher.Close() }
+	case "log":
+		return events.NewLogPublisher(logger), func() {}
+	default:
+		fatal(logger, "Unknown event publisher", fmt.Errorf("%q", cfg.EventPublisher))
+		return nil, nil
+	}
 }
 
-func runMigrations(db *sqlx.DB) {
+// runMigrations накатывает миграции и возвращает версию схемы, с которой стартует сервис
+func runMigrations(logger *slog.Logger, db *sqlx.DB) uint {
 	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
 	if err != nil {
-		log.Fatalf("could not create migrate driver: %v", err)
+		fatal(logger, "could not create migrate driver", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
 		"file://migrations",
 		"postgres", driver)
 	if err != nil {
-		log.Fatalf("could not create migrate instance: %v", err)
+		fatal(logger, "could not create migrate instance", err)
 	}
 
 	if err := m.Up(); err != nil {
 		if errors.Is(err, migrate.ErrNoChange) {
-			log.Println("Database is up to date (no migrations to apply)")
+			logger.Info("Database is up to date (no migrations to apply)")
 		} else {
-			log.Fatalf("could not run up migrations: %v", err)
+			fatal(logger, "could not run up migrations", err)
 		}
 	} else {
-		log.Println("Migrations applied successfully!")
+		logger.Info("Migrations applied successfully")
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		fatal(logger, "could not read migration version", err)
 	}
+	return version
+}
+
+// fatal аналог log.Fatal для slog
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
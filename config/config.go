@@ -1,9 +1,22 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/database"
+)
+
+// MinJWTSecretLength короче ключ HS256 подбирается перебором
+const MinJWTSecretLength = 32
+
+var (
+	ErrWeakJWTSecret = errors.New("JWT_SECRET is not set or too short")
+	ErrInvalidConfig = errors.New("invalid configuration")
 )
 
 type Config struct {
@@ -14,15 +27,78 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 
+	DBMaxOpenConns    int           // верхняя граница пула соединений
+	DBMaxIdleConns    int           // сколько соединений держим открытыми без работы
+	DBConnMaxLifetime time.Duration // через сколько соединение переоткрывается
+	DBConnMaxIdleTime time.Duration // сколько простаивающее соединение живёт в пуле
+	DBConnectAttempts int           // попытки подключения при старте
+	DBConnectBackoff  time.Duration // начальная пауза между попытками, дальше растёт вдвое
+
 	RedisAddr string // для Redis
 	AppPort   string // порт Redis
+	GRPCPort  string // порт gRPC сервера
+	AdminPort string // служебный порт: /metrics, наружу не публикуется
+
+	LogFormat string // формат логов: json или text
+	LogLevel  string // debug, info, warn, error
+
+	RedisTimeout         time.Duration // таймаут подключения, чтения и записи в Redis
+	CacheBreakerFailures int           // после скольких ошибок Redis подряд кеш отключается
+	CacheBreakerTimeout  time.Duration // через сколько пробуем Redis снова
+
+	LocalCacheSize int           // записей в LRU каждого кеша в памяти, 0 отключает этот уровень
+	LocalCacheTTL  time.Duration // сколько запись живёт в памяти, если инвалидация не дошла
+
+	// CacheCodec формат значений в Redis: json, msgpack или protobuf. Читаются все форматы,
+	// но менять его и включать сжатие можно только когда все реплики уже умеют их читать:
+	// несжатый json без заголовка понимают и версии до появления кодеков
+	CacheCodec             string
+	CacheCompressThreshold int // с какого размера значения в байтах сжимаются gzip, 0 отключает
+
+	HealthCheckTimeout time.Duration // таймаут одной проверки в /readyz
+	ShutdownDrainDelay time.Duration // сколько /readyz отвечает 503 до остановки HTTP сервера
+
+	TraceExporter     string // none, stdout или otlp
+	TraceFile         string // файл для stdout экспортёра, пусто значит stdout
+	TraceOTLPEndpoint string // host:port коллектора для OTLP/HTTP
+
+	JWTSecret       string        // ключ подписи access токенов, значения по умолчанию нет
+	AccessTokenTTL  time.Duration // время жизни access токена
+	RefreshTokenTTL time.Duration // время жизни refresh токена
+
+	CartTTL        time.Duration // сколько живёт корзина без изменений
+	IdempotencyTTL time.Duration // сколько помним ответ на запрос с Idempotency-Key
+
+	OutboxInterval    time.Duration // как часто relay проверяет outbox
+	OutboxBatchSize   int           // сколько событий relay берёт за раз
+	OutboxMaxAttempts int           // после скольких неудач событие уходит в dead
+
+	EventPublisher    string        // куда relay отправляет события: log или kafka
+	KafkaBrokers      []string      // адреса брокеров через запятую
+	KafkaTopicPrefix  string        // топики получаются как <prefix>.order, <prefix>.user
+	KafkaEncoding     string        // формат сообщений: json или protobuf
+	KafkaWriteTimeout time.Duration // таймаут записи в кластер
 }
 
-func (c *Config) GetDBDSN() string {
-	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode,
-	)
+func (c *Config) Database() database.Config {
+	return database.Config{
+		Host:     c.DBHost,
+		Port:     c.DBPort,
+		User:     c.DBUser,
+		Password: c.DBPassword,
+		DBName:   c.DBName,
+		SSLMode:  c.DBSSLMode,
+
+		MaxOpenConns:    c.DBMaxOpenConns,
+		MaxIdleConns:    c.DBMaxIdleConns,
+		ConnMaxLifetime: c.DBConnMaxLifetime,
+		ConnMaxIdleTime: c.DBConnMaxIdleTime,
+
+		ConnectAttempts: c.DBConnectAttempts,
+		ConnectBackoff:  c.DBConnectBackoff,
+		MaxBackoff:      30 * time.Second,
+		PingTimeout:     5 * time.Second,
+	}
 }
 
 func Load() *Config {
@@ -34,9 +110,73 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "commerce"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
 
+		DBMaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 25),
+		DBConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBConnectAttempts: getEnvAsInt("DB_CONNECT_ATTEMPTS", 8),
+		DBConnectBackoff:  getEnvAsDuration("DB_CONNECT_BACKOFF", 500*time.Millisecond),
+
 		RedisAddr: getEnv("REDIS_URL", "localhost:6379"),
 		AppPort:   getEnv("APP_PORT", "8080"),
+		GRPCPort:  getEnv("GRPC_PORT", "9090"),
+		AdminPort: getEnv("ADMIN_PORT", "8081"),
+
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+
+		RedisTimeout:         getEnvAsDuration("REDIS_TIMEOUT", 500*time.Millisecond),
+		CacheBreakerFailures: getEnvAsInt("CACHE_BREAKER_FAILURES", 5),
+		CacheBreakerTimeout:  getEnvAsDuration("CACHE_BREAKER_TIMEOUT", 10*time.Second),
+
+		LocalCacheSize: getEnvAsInt("LOCAL_CACHE_SIZE", 10000),
+		LocalCacheTTL:  getEnvAsDuration("LOCAL_CACHE_TTL", 30*time.Second),
+
+		CacheCodec:             getEnv("CACHE_CODEC", "json"),
+		CacheCompressThreshold: getEnvAsInt("CACHE_COMPRESS_THRESHOLD", 0),
+
+		HealthCheckTimeout: getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		ShutdownDrainDelay: getEnvAsDuration("SHUTDOWN_DRAIN_DELAY", 3*time.Second),
+
+		TraceExporter:     getEnv("TRACE_EXPORTER", "none"),
+		TraceFile:         getEnv("TRACE_FILE", ""),
+		TraceOTLPEndpoint: getEnv("TRACE_OTLP_ENDPOINT", "localhost:4318"),
+
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		AccessTokenTTL:  getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		CartTTL:        getEnvAsDuration("CART_TTL", 7*24*time.Hour),
+		IdempotencyTTL: getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		OutboxInterval:    getEnvAsDuration("OUTBOX_INTERVAL", time.Second),
+		OutboxBatchSize:   getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxMaxAttempts: getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		EventPublisher:    getEnv("EVENT_PUBLISHER", "log"),
+		KafkaBrokers:      getEnvAsSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
+		KafkaTopicPrefix:  getEnv("KAFKA_TOPIC_PREFIX", "commerce"),
+		KafkaEncoding:     getEnv("KAFKA_ENCODING", "json"),
+		KafkaWriteTimeout: getEnvAsDuration("KAFKA_WRITE_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Validate проверяет настройки, без которых сервис нельзя запускать
+func (c *Config) Validate() error {
+	if len(c.JWTSecret) < MinJWTSecretLength {
+		return fmt.Errorf("%w: need at least %d bytes", ErrWeakJWTSecret, MinJWTSecretLength)
+	}
+	// С нулевым интервалом time.NewTicker паникует, с нулевой пачкой relay ничего не отправит
+	if c.OutboxInterval <= 0 {
+		return fmt.Errorf("%w: OUTBOX_INTERVAL must be positive, got %s", ErrInvalidConfig, c.OutboxInterval)
 	}
+	if c.OutboxBatchSize <= 0 {
+		return fmt.Errorf("%w: OUTBOX_BATCH_SIZE must be positive, got %d", ErrInvalidConfig, c.OutboxBatchSize)
+	}
+	if c.OutboxMaxAttempts <= 0 {
+		return fmt.Errorf("%w: OUTBOX_MAX_ATTEMPTS must be positive, got %d", ErrInvalidConfig, c.OutboxMaxAttempts)
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -54,3 +194,27 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			JWTSecret:         strings.Repeat("k", MinJWTSecretLength),
+			OutboxInterval:    time.Second,
+			OutboxBatchSize:   100,
+			OutboxMaxAttempts: 10,
+		}
+	}
+	assert.NoError(t, valid().Validate())
+
+	cfg := valid()
+	cfg.JWTSecret = "secret"
+	assert.ErrorIs(t, cfg.Validate(), ErrWeakJWTSecret)
+
+	cfg = valid()
+	cfg.OutboxInterval = 0
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig)
+
+	cfg = valid()
+	cfg.OutboxBatchSize = -1
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig)
+
+	cfg = valid()
+	cfg.OutboxMaxAttempts = 0
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig)
+}
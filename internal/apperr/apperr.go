@@ -0,0 +1,178 @@
+// Package apperr единая таблица доменных ошибок. REST и gRPC берут из неё вид ошибки
+// и стабильный код, а HTTP статус и код gRPC выводятся из вида
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Belixk/CommerceTwo/internal/cache"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pkg/mergepatch"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Коды ошибок стабильны, клиенты должны опираться на них, а не на текст detail
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeRequestTooLarge      = "request_too_large"
+	CodeReadOnlyField        = "read_only_field"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidRefreshToken  = "invalid_refresh_token"
+	CodeForbidden            = "forbidden"
+	CodeUserNotFound         = "user_not_found"
+	CodeOrderNotFound        = "order_not_found"
+	CodeOrderItemNotFound    = "order_item_not_found"
+	CodeProductNotFound      = "product_not_found"
+	CodeEmailExists          = "email_exists"
+	CodeSKUExists            = "sku_exists"
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeInvalidFilter        = "invalid_filter"
+	CodeUnknownRole          = "unknown_role"
+	CodeUnknownOrderStatus   = "unknown_order_status"
+	CodeInvalidTransition    = "invalid_status_transition"
+	CodeStatusConflict       = "order_status_conflict"
+	CodeOrderNotEditable     = "order_not_editable"
+	CodeInsufficientStock    = "insufficient_stock"
+	CodeProductUnavailable   = "product_unavailable"
+	CodeCartEmpty            = "cart_empty"
+	CodeIdempotencyInFlight  = "idempotency_in_progress"
+	CodeIdempotencyMismatch  = "idempotency_key_reused"
+	CodeUnavailable          = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// Kind вид ошибки, общий для всех транспортов
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalidArgument
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	KindAlreadyExists
+	KindFailedPrecondition // состояние ресурса не допускает операцию
+	KindConflict           // ресурс изменили параллельно, запрос можно повторить
+	KindUnprocessable      // запрос корректен, но ссылается на непригодные данные
+	KindUnavailable
+)
+
+// HTTPStatus статус ответа REST ручек
+func (k Kind) HTTPStatus() int {
+	switch k {
+	case KindInvalidArgument:
+		return http.StatusBadRequest
+	case KindUnauthenticated:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindAlreadyExists, KindFailedPrecondition, KindConflict:
+		return http.StatusConflict
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GRPCCode код ответа gRPC сервера
+func (k Kind) GRPCCode() codes.Code {
+	switch k {
+	case KindInvalidArgument:
+		return codes.InvalidArgument
+	case KindUnauthenticated:
+		return codes.Unauthenticated
+	case KindForbidden:
+		return codes.PermissionDenied
+	case KindNotFound:
+		return codes.NotFound
+	case KindAlreadyExists:
+		return codes.AlreadyExists
+	case KindFailedPrecondition, KindUnprocessable:
+		return codes.FailedPrecondition
+	case KindConflict:
+		return codes.Aborted
+	case KindUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+type mapping struct {
+	target error
+	kind   Kind
+	code   string
+}
+
+// mappings порядок важен, побеждает первое совпадение по errors.Is
+var mappings = []mapping{
+	{policy.ErrUnauthenticated, KindUnauthenticated, CodeUnauthenticated},
+	{policy.ErrForbidden, KindForbidden, CodeForbidden},
+	{services.ErrInvalidCredentials, KindUnauthenticated, CodeInvalidCredentials},
+	{services.ErrInvalidRefreshToken, KindUnauthenticated, CodeInvalidRefreshToken},
+
+	{repositories.ErrUserNotFound, KindNotFound, CodeUserNotFound},
+	{repositories.ErrOrderNotFound, KindNotFound, CodeOrderNotFound},
+	{repositories.ErrProductNotFound, KindNotFound, CodeProductNotFound},
+	{repositories.ErrOrderItemNotFound, KindInvalidArgument, CodeOrderItemNotFound},
+
+	{repositories.ErrEmailExists, KindAlreadyExists, CodeEmailExists},
+	{repositories.ErrSKUExists, KindAlreadyExists, CodeSKUExists},
+
+	{entity.ErrInvalidFirstName, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidLastName, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidEmail, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidAge, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidSKU, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidProductName, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidPrice, KindInvalidArgument, CodeValidationFailed},
+	{entity.ErrInvalidStock, KindInvalidArgument, CodeValidationFailed},
+	{services.ErrPasswordTooShort, KindInvalidArgument, CodeValidationFailed},
+	{services.ErrOrderNil, KindInvalidArgument, CodeValidationFailed},
+	{services.ErrOrderNoItems, KindInvalidArgument, CodeValidationFailed},
+	{services.ErrInvalidCartID, KindInvalidArgument, CodeValidationFailed},
+	{services.ErrInvalidQuantity, KindInvalidArgument, CodeValidationFailed},
+	{mergepatch.ErrInvalidPatch, KindInvalidArgument, CodeInvalidRequest},
+
+	{entity.ErrInvalidCursor, KindInvalidArgument, CodeInvalidCursor},
+	{entity.ErrInvalidFilter, KindInvalidArgument, CodeInvalidFilter},
+	{services.ErrUnknownRole, KindInvalidArgument, CodeUnknownRole},
+	{services.ErrUnknownOrderStatus, KindInvalidArgument, CodeUnknownOrderStatus},
+
+	{repositories.ErrOrderStatusConflict, KindConflict, CodeStatusConflict},
+	{services.ErrOrderNotEditable, KindFailedPrecondition, CodeOrderNotEditable},
+	{repositories.ErrInsufficientStock, KindFailedPrecondition, CodeInsufficientStock},
+	{services.ErrCartEmpty, KindFailedPrecondition, CodeCartEmpty},
+	{services.ErrProductUnavailable, KindUnprocessable, CodeProductUnavailable},
+
+	{cache.ErrUnavailable, KindUnavailable, CodeUnavailable},
+}
+
+// Classify находит ошибку в таблице. Для неизвестных ошибок ok == false,
+// их транспорт пишет в лог и отдаёт клиенту как внутреннюю без подробностей
+func Classify(err error) (kind Kind, code string, ok bool) {
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			return m.kind, m.code, true
+		}
+	}
+
+	var transitionErr *services.InvalidTransitionError
+	if errors.As(err, &transitionErr) {
+		return KindFailedPrecondition, CodeInvalidTransition, true
+	}
+	return KindInternal, CodeInternal, false
+}
@@ -0,0 +1,24 @@
+package apperr
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMappings_EveryKindHasTransportCodes(t *testing.T) {
+	// Ошибка из таблицы не должна превращаться в 500 ни в одном транспорте
+	for _, m := range mappings {
+		t.Run(m.target.Error(), func(t *testing.T) {
+			kind, code, ok := Classify(fmt.Errorf("wrapped: %w", m.target))
+			assert.True(t, ok)
+			assert.NotEqual(t, KindInternal, kind)
+			assert.NotEmpty(t, code)
+			assert.NotEqual(t, http.StatusInternalServerError, kind.HTTPStatus())
+			assert.NotEqual(t, codes.Internal, kind.GRPCCode())
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+)
+
+// ErrUnavailable Redis признан недоступным, запрос в него не отправлялся. Сервисы считают это промахом
+var ErrUnavailable = errors.New("cache unavailable")
+
+// maxPending сколько несостоявшихся инвалидаций помним до восстановления Redis
+const maxPending = 10000
+
+// Breaker общий для всех кешей поверх одного Redis. После failures ошибок подряд кеш
+// пропускается на openTimeout, затем пробные запросы проверяют, поднялся ли Redis
+type Breaker struct {
+	cb     *gobreaker.CircuitBreaker
+	logger *slog.Logger
+}
+
+func NewBreaker(logger *slog.Logger, failures uint32, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		logger: logger,
+		cb: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "redis",
+			Timeout: openTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= failures
+			},
+			IsSuccessful: isSuccessful,
+			OnStateChange: func(_ string, from, to gobreaker.State) {
+				logger.Warn("Cache circuit breaker changed state",
+					slog.String("from", from.String()), slog.String("to", to.String()))
+			},
+		}),
+	}
+}
+
+// isSuccessful промах, закешированное "не найдено", значение чужого формата,
+// занятый чужой блокировкой ключ и упёршаяся в лимит корзина это нормальные ответы Redis
+func isSuccessful(err error) bool {
+	return err == nil ||
+		errors.Is(err, redis.Nil) ||
+		errors.Is(err, codec.ErrUnknownFormat) ||
+		errors.Is(err, repositories.ErrUserNotFound) ||
+		errors.Is(err, repositories.ErrOrderNotFound) ||
+		errors.Is(err, repositories.ErrRefreshTokenNotFound) ||
+		errors.Is(err, repositories.ErrIdempotencyLockLost) ||
+		errors.Is(err, repositories.ErrCartQuantityLimit)
+}
+
+// Check для /readyz: ошибка, пока кеш работает не в обычном режиме
+func (b *Breaker) Check(context.Context) error {
+	if state := b.cb.State(); state != gobreaker.StateClosed {
+		return fmt.Errorf("%w: circuit %s", ErrUnavailable, state)
+	}
+	return nil
+}
+
+func call[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	res, err := b.cb.Execute(func() (any, error) {
+		return fn()
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		err = ErrUnavailable
+	}
+	value, _ := res.(T)
+	return value, err
+}
+
+// exec то же для вызовов без результата
+func exec(b *Breaker, fn func() error) error {
+	_, err := call(b, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// pending инвалидации, которые не дошли до Redis. Без них после восстановления
+// Redis отдал бы записи, изменённые в БД, пока он лежал
+type pending struct {
+	mu        sync.Mutex
+	keys      map[string]struct{}
+	dropped   int
+	replaying atomic.Bool
+}
+
+func (p *pending) add(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys == nil {
+		p.keys = make(map[string]struct{})
+	}
+	if len(p.keys) >= maxPending {
+		p.dropped++
+		return
+	}
+	p.keys[key] = struct{}{}
+}
+
+// has ключ ждёт удаления, читать его из Redis нельзя
+func (p *pending) has(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.keys[key]
+	return ok
+}
+
+// replay повторяет отложенные удаления в фоне, один проход за раз
+func (p *pending) replay(logger *slog.Logger, del func(ctx context.Context, key string) error) {
+	p.mu.Lock()
+	empty := len(p.keys) == 0 && p.dropped == 0
+	p.mu.Unlock()
+	if empty || !p.replaying.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer p.replaying.Store(false)
+
+		p.mu.Lock()
+		keys := slices.Collect(maps.Keys(p.keys))
+		dropped := p.dropped
+		p.dropped = 0
+		p.mu.Unlock()
+
+		if dropped > 0 {
+			logger.Error("Cache invalidations were lost while Redis was down, entries may be stale until TTL",
+				slog.Int("dropped", dropped))
+		}
+
+		// Ключ остаётся в pending до успешного удаления, до тех пор его не читаем
+		ctx := context.Background()
+		for _, key := range keys {
+			if err := del(ctx, key); err != nil {
+				return
+			}
+			p.mu.Lock()
+			delete(p.keys, key)
+			p.mu.Unlock()
+		}
+	}()
+}
+
+type protectedUserCache struct {
+	next    services.UserCache
+	breaker *Breaker
+	pending pending
+}
+
+// ProtectUserCache пропускает кеш пользователей через breaker
+func ProtectUserCache(next services.UserCache, breaker *Breaker) services.UserCache {
+	return &protectedUserCache{next: next, breaker: breaker}
+}
+
+func (c *protectedUserCache) Get(ctx context.Context, key string) (*entity.User, error) {
+	if c.pending.has(key) {
+		return nil, ErrUnavailable
+	}
+	user, err := call(c.breaker, func() (*entity.User, error) {
+		return c.next.Get(ctx, key)
+	})
+	c.afterCall(err)
+	return user, err
+}
+
+func (c *protectedUserCache) Set(ctx context.Context, key string, user *entity.User, ttl time.Duration) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.Set(ctx, key, user, ttl)
+	})
+	if user == nil && err != nil {
+		c.pending.add(key)
+	}
+	c.afterCall(err)
+	return err
+}
+
+func (c *protectedUserCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.SetNotFound(ctx, key, ttl)
+	})
+	c.afterCall(err)
+	return err
+}
+
+func (c *protectedUserCache) afterCall(err error) {
+	if isSuccessful(err) {
+		c.pending.replay(c.breaker.logger, c.invalidate)
+	}
+}
+
+func (c *protectedUserCache) invalidate(ctx context.Context, key string) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.Set(ctx, key, nil, 0)
+	})
+	return err
+}
+
+type protectedOrderCache struct {
+	next    services.Cache
+	breaker *Breaker
+	pending pending
+}
+
+// ProtectOrderCache то же для кеша заказов и страниц списков
+func ProtectOrderCache(next services.Cache, breaker *Breaker) services.Cache {
+	return &protectedOrderCache{next: next, breaker: breaker}
+}
+
+func (c *protectedOrderCache) Get(ctx context.Context, key string) (*entity.Order, error) {
+	if c.pending.has(key) {
+		return nil, ErrUnavailable
+	}
+	order, err := call(c.breaker, func() (*entity.Order, error) {
+		return c.next.Get(ctx, key)
+	})
+	c.afterCall(err)
+	return order, err
+}
+
+func (c *protectedOrderCache) Set(ctx context.Context, key string, order *entity.Order, ttl time.Duration) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.Set(ctx, key, order, ttl)
+	})
+	if order == nil && err != nil {
+		c.pending.add(key)
+	}
+	c.afterCall(err)
+	return err
+}
+
+func (c *protectedOrderCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.SetNotFound(ctx, key, ttl)
+	})
+	c.afterCall(err)
+	return err
+}
+
+func (c *protectedOrderCache) GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error) {
+	if c.pending.has(key) {
+		return nil, ErrUnavailable
+	}
+	page, err := call(c.breaker, func() (*entity.OrderPage, error) {
+		return c.next.GetPage(ctx, key, field)
+	})
+	c.afterCall(err)
+	return page, err
+}
+
+func (c *protectedOrderCache) SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.SetPage(ctx, key, field, page, ttl)
+	})
+	c.afterCall(err)
+	return err
+}
+
+func (c *protectedOrderCache) afterCall(err error) {
+	if isSuccessful(err) {
+		c.pending.replay(c.breaker.logger, c.invalidate)
+	}
+}
+
+func (c *protectedOrderCache) invalidate(ctx context.Context, key string) error {
+	_, err := call(c.breaker, func() (struct{}, error) {
+		return struct{}{}, c.next.Set(ctx, key, nil, 0)
+	})
+	return err
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errConnRefused = errors.New("dial tcp: connection refused")
+
+// flakyUserCache хранилище, которое можно "уронить"
+type flakyUserCache struct {
+	services.UserCache
+	mu    sync.Mutex
+	down  bool
+	calls int
+	data  map[string]*entity.User
+}
+
+func (c *flakyUserCache) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *flakyUserCache) Get(_ context.Context, key string) (*entity.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.down {
+		return nil, errConnRefused
+	}
+	user, ok := c.data[key]
+	if !ok {
+		return nil, redis.Nil
+	}
+	return user, nil
+}
+
+func (c *flakyUserCache) Set(_ context.Context, key string, user *entity.User, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.down {
+		return errConnRefused
+	}
+	if user == nil {
+		delete(c.data, key)
+		return nil
+	}
+	c.data[key] = user
+	return nil
+}
+
+func (c *flakyUserCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.data[key]
+	return ok
+}
+
+func TestProtectUserCache_OpensAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	next := &flakyUserCache{data: map[string]*entity.User{}, down: true}
+	c := ProtectUserCache(next, NewBreaker(slog.New(slog.DiscardHandler), 3, time.Minute))
+	breaker := c.(*protectedUserCache).breaker
+
+	for range 3 {
+		_, err := c.Get(ctx, "user:1")
+		assert.ErrorIs(t, err, errConnRefused)
+	}
+	assert.ErrorIs(t, breaker.Check(ctx), ErrUnavailable)
+
+	// Дальше Redis не трогаем
+	_, err := c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 3, next.calls)
+}
+
+func TestProtectUserCache_MissIsNotFailure(t *testing.T) {
+	ctx := context.Background()
+	c := ProtectUserCache(&flakyUserCache{data: map[string]*entity.User{}}, NewBreaker(slog.New(slog.DiscardHandler), 1, time.Minute))
+
+	for range 3 {
+		_, err := c.Get(ctx, "user:1")
+		assert.ErrorIs(t, err, redis.Nil)
+	}
+	assert.NoError(t, c.(*protectedUserCache).breaker.Check(ctx))
+}
+
+func TestProtectUserCache_ReplaysInvalidationsAfterRecovery(t *testing.T) {
+	ctx := context.Background()
+	next := &flakyUserCache{data: map[string]*entity.User{"user:1": {ID: 1, FirstName: "old"}}}
+	c := ProtectUserCache(next, NewBreaker(slog.New(slog.DiscardHandler), 1, 20*time.Millisecond))
+
+	// Пользователя обновили в БД, пока Redis лежал: удаление не дошло
+	next.setDown(true)
+	assert.Error(t, c.Set(ctx, "user:1", nil, 0))
+	next.setDown(false)
+
+	// Старую запись не читаем, даже когда Redis снова отвечает
+	time.Sleep(30 * time.Millisecond)
+	_, err := c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	// Любой успешный вызов запускает отложенные удаления
+	_, _ = c.Get(ctx, "user:2")
+	require.Eventually(t, func() bool { return !next.has("user:1") }, time.Second, 5*time.Millisecond)
+
+	_, err = c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+// downCartStore корзины без Redis
+type downCartStore struct {
+	services.CartStore
+	calls int
+}
+
+func (s *downCartStore) Items(context.Context, string) (map[int64]int, error) {
+	s.calls++
+	return nil, errConnRefused
+}
+
+func TestProtectCartStore_SharesBreaker(t *testing.T) {
+	ctx := context.Background()
+	breaker := NewBreaker(slog.New(slog.DiscardHandler), 2, time.Minute)
+	users := ProtectUserCache(&flakyUserCache{data: map[string]*entity.User{}, down: true}, breaker)
+	next := &downCartStore{}
+	carts := ProtectCartStore(next, breaker)
+
+	// Ошибки кеша пользователей открывают breaker и для корзин
+	for range 2 {
+		_, _ = users.Get(ctx, "user:1")
+	}
+
+	_, err := carts.Items(ctx, "cart:1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Zero(t, next.calls)
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingUserCache считает обращения к нижнему уровню
+type countingUserCache struct {
+	services.UserCache
+	gets int
+}
+
+func (c *countingUserCache) Get(ctx context.Context, key string) (*entity.User, error) {
+	c.gets++
+	return c.UserCache.Get(ctx, key)
+}
+
+var jsonCodec, _ = codec.New(codec.FormatJSON, 0)
+
+func newRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestUserCache_ServesFromMemory(t *testing.T) {
+	ctx := context.Background()
+	redisTier := &countingUserCache{UserCache: repositories.NewUserCache(newRedis(t), jsonCodec)}
+	c := NewUserCache(redisTier, nil, 100, time.Minute)
+
+	require.NoError(t, c.Set(ctx, "user:1", &entity.User{ID: 1, FirstName: "Maxim", PasswordHash: "secret"}, time.Minute))
+
+	user, err := c.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, "Maxim", user.FirstName)
+	assert.Empty(t, user.PasswordHash)
+	assert.Equal(t, 0, redisTier.gets)
+
+	// Изменение полученной копии не портит запись
+	user.FirstName = "changed"
+	again, _ := c.Get(ctx, "user:1")
+	assert.Equal(t, "Maxim", again.FirstName)
+}
+
+func TestUserCache_NotFound(t *testing.T) {
+	ctx := context.Background()
+	redisTier := &countingUserCache{UserCache: repositories.NewUserCache(newRedis(t), jsonCodec)}
+	c := NewUserCache(redisTier, nil, 100, time.Minute)
+
+	require.NoError(t, c.SetNotFound(ctx, "user:404", time.Minute))
+
+	_, err := c.Get(ctx, "user:404")
+	assert.ErrorIs(t, err, repositories.ErrUserNotFound)
+	assert.Equal(t, 0, redisTier.gets)
+
+	_, err = c.Get(ctx, "user:405")
+	assert.True(t, errors.Is(err, redis.Nil))
+	assert.Equal(t, 1, redisTier.gets)
+}
+
+func TestOrderCache_StaleReadDoesNotSurviveInvalidation(t *testing.T) {
+	ctx := context.Background()
+	client := newRedis(t)
+	c := NewOrderCache(repositories.NewOrderCache(client, jsonCodec), nil, 100, time.Minute).(*orderCache)
+
+	require.NoError(t, client.Set(ctx, "order:1", `{"id":1,"total":100}`, 0).Err())
+
+	// Чтение из Redis началось до инвалидации и закончилось после неё
+	gen := c.local.generation.Load()
+	require.NoError(t, c.Set(ctx, "order:1", nil, 0))
+	c.local.add(gen, "order:1", &entity.Order{ID: 1, Total: 100})
+
+	_, ok := c.local.get("order:1")
+	assert.False(t, ok)
+}
+
+func TestBus_InvalidatesOtherReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := newRedis(t)
+	logger := slog.New(slog.DiscardHandler)
+
+	// Две реплики с общим Redis и своими шинами
+	busA, busB := NewBus(client, nil, logger), NewBus(client, nil, logger)
+	replicaA := NewUserCache(repositories.NewUserCache(client, jsonCodec), busA, 100, time.Minute)
+	replicaB := NewUserCache(repositories.NewUserCache(client, jsonCodec), busB, 100, time.Minute).(*userCache)
+	go busA.Run(ctx)
+	go busB.Run(ctx)
+	waitSubscribers(t, client, 2)
+
+	// Подписка сама очищает память, поэтому кладём запись, пока она не удержится
+	require.Eventually(t, func() bool {
+		if err := replicaB.Set(ctx, "user:1", &entity.User{ID: 1, FirstName: "old"}, time.Minute); err != nil {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+		_, ok := replicaB.local.get("user:1")
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, replicaA.Set(ctx, "user:1", nil, 0))
+
+	assert.Eventually(t, func() bool {
+		_, ok := replicaB.local.get("user:1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+	_, err := replicaB.Get(ctx, "user:1")
+	assert.True(t, errors.Is(err, redis.Nil))
+}
+
+func waitSubscribers(t *testing.T, client *redis.Client, n int64) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		counts, err := client.PubSubNumSub(context.Background(), InvalidationChannel).Result()
+		return err == nil && counts[InvalidationChannel] == n
+	}, time.Second, 5*time.Millisecond)
+}
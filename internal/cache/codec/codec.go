@@ -0,0 +1,151 @@
+// Package codec формат значений кеша в Redis: сериализация, сжатие и заголовок с версией
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Заголовок значения: magic, версия заголовка, формат, флаги. 0xC1 не встречается
+// ни в JSON, ни в UTF-8, так что значения без заголовка (старый JSON) от новых отличаются
+const (
+	magic         byte = 0xC1
+	headerVersion byte = 1
+	headerSize         = 4
+
+	flagGzip byte = 1 << 0
+)
+
+// Форматы, которые можно выбрать в конфиге
+const (
+	FormatJSON     = "json"
+	FormatMsgpack  = "msgpack"
+	FormatProtobuf = "protobuf"
+)
+
+var (
+	// ErrUnknownFormat значение записано версией, которая этой неизвестна. Для кеша это промах
+	ErrUnknownFormat = errors.New("unknown cache value format")
+	ErrUnsupported   = errors.New("type is not supported by cache codec")
+)
+
+// serializer превращает сущность в байты. id пишется в заголовок и не должен меняться
+type serializer interface {
+	id() byte
+	marshal(v any) ([]byte, error)
+	unmarshal(data []byte, v any) error
+}
+
+var serializers = map[byte]serializer{
+	jsonSerializer{}.id():     jsonSerializer{},
+	msgpackSerializer{}.id():  msgpackSerializer{},
+	protobufSerializer{}.id(): protobufSerializer{},
+}
+
+// Codec пишет значения в выбранном формате, а читает любые известные: во время выкладки
+// реплики с разными настройками делят один Redis
+type Codec struct {
+	serializer    serializer
+	compressAbove int
+}
+
+// New format один из Format*, compressAbove размер в байтах, начиная с которого значение
+// сжимается gzip. 0 отключает сжатие
+func New(format string, compressAbove int) (*Codec, error) {
+	var s serializer
+	switch format {
+	case FormatJSON, "":
+		s = jsonSerializer{}
+	case FormatMsgpack:
+		s = msgpackSerializer{}
+	case FormatProtobuf:
+		s = protobufSerializer{}
+	default:
+		return nil, fmt.Errorf("unknown cache codec %q", format)
+	}
+	return &Codec{serializer: s, compressAbove: compressAbove}, nil
+}
+
+func (c *Codec) Encode(v any) ([]byte, error) {
+	payload, err := c.serializer.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if c.compressAbove > 0 && len(payload) >= c.compressAbove {
+		if payload, err = compress(payload); err != nil {
+			return nil, err
+		}
+		flags |= flagGzip
+	}
+
+	// Несжатый JSON пишем без заголовка: так его читают и реплики, собранные до появления кодеков
+	if flags == 0 && c.serializer.id() == (jsonSerializer{}).id() {
+		return payload, nil
+	}
+
+	data := make([]byte, 0, headerSize+len(payload))
+	data = append(data, magic, headerVersion, c.serializer.id(), flags)
+	return append(data, payload...), nil
+}
+
+// Decode любое нечитаемое значение, включая битое, возвращает как ErrUnknownFormat:
+// для кеша это промах, а не отказ Redis
+func (c *Codec) Decode(data []byte, v any) error {
+	// Значение без заголовка это JSON
+	if len(data) == 0 || data[0] != magic {
+		return unreadable(jsonSerializer{}.unmarshal(data, v))
+	}
+	if len(data) < headerSize || data[1] != headerVersion {
+		return ErrUnknownFormat
+	}
+
+	s, ok := serializers[data[2]]
+	if !ok {
+		return ErrUnknownFormat
+	}
+	flags, payload := data[3], data[headerSize:]
+	if flags&^flagGzip != 0 {
+		return ErrUnknownFormat
+	}
+
+	if flags&flagGzip != 0 {
+		var err error
+		if payload, err = decompress(payload); err != nil {
+			return unreadable(err)
+		}
+	}
+	return unreadable(s.unmarshal(payload, v))
+}
+
+func unreadable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
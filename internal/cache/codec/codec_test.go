@@ -0,0 +1,170 @@
+package codec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var formats = []string{FormatJSON, FormatMsgpack, FormatProtobuf}
+
+func testUser() *entity.User {
+	return &entity.User{
+		ID:           1,
+		FirstName:    "Ivan",
+		LastName:     "Petrov",
+		Email:        "ivan@example.com",
+		PasswordHash: "secret",
+		Role:         "admin",
+		Age:          30,
+		CreatedAt:    time.Date(2024, 5, 1, 10, 0, 0, 123000, time.UTC),
+		UpdatedAt:    time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func testOrder() *entity.Order {
+	return &entity.Order{
+		ID:     7,
+		UserID: 1,
+		Status: entity.OrderStatus("new"),
+		Items: []entity.OrderItem{
+			{ID: 1, OrderID: 7, ProductID: 3, Name: "Keyboard", Quantity: 2, Price: 1500},
+		},
+		Total:     3000,
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// utc время после msgpack приходит в локальной зоне, сравниваем моменты
+func utc(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, format := range formats {
+		t.Run(format, func(t *testing.T) {
+			c, err := New(format, 0)
+			require.NoError(t, err)
+
+			data, err := c.Encode(testUser())
+			require.NoError(t, err)
+			var user entity.User
+			require.NoError(t, c.Decode(data, &user))
+			user.CreatedAt, user.UpdatedAt = utc(user.CreatedAt), utc(user.UpdatedAt)
+
+			want := testUser()
+			want.PasswordHash = "" // хеш пароля в кеш не попадает
+			assert.Equal(t, want, &user)
+
+			page := &entity.OrderPage{Orders: []entity.Order{*testOrder()}, NextCursor: "abc"}
+			data, err = c.Encode(page)
+			require.NoError(t, err)
+			var got entity.OrderPage
+			require.NoError(t, c.Decode(data, &got))
+			require.Len(t, got.Orders, 1)
+			got.Orders[0].CreatedAt = utc(got.Orders[0].CreatedAt)
+			assert.Equal(t, page, &got)
+		})
+	}
+}
+
+func TestCodec_ReadsEveryFormat(t *testing.T) {
+	// Реплика с json читает то, что записала реплика с protobuf, и наоборот
+	writer, err := New(FormatProtobuf, 0)
+	require.NoError(t, err)
+	reader, err := New(FormatJSON, 0)
+	require.NoError(t, err)
+
+	data, err := writer.Encode(testOrder())
+	require.NoError(t, err)
+
+	var order entity.Order
+	require.NoError(t, reader.Decode(data, &order))
+	assert.Equal(t, int64(3000), order.Total)
+	assert.Equal(t, "Keyboard", order.Items[0].Name)
+}
+
+func TestCodec_Compression(t *testing.T) {
+	c, err := New(FormatJSON, 64)
+	require.NoError(t, err)
+
+	small, err := c.Encode(&entity.OrderPage{})
+	require.NoError(t, err)
+	assert.NotEqual(t, magic, small[0])
+
+	user := testUser()
+	user.FirstName = strings.Repeat("a", 1000)
+	data, err := c.Encode(user)
+	require.NoError(t, err)
+	assert.NotZero(t, data[3]&flagGzip)
+	assert.Less(t, len(data), 1000)
+
+	var got entity.User
+	require.NoError(t, c.Decode(data, &got))
+	assert.Equal(t, user.FirstName, got.FirstName)
+}
+
+func TestCodec_LegacyJSON(t *testing.T) {
+	// Значения, записанные до кодеков, остаются читаемыми
+	legacy, err := json.Marshal(testUser())
+	require.NoError(t, err)
+
+	c, err := New(FormatMsgpack, 0)
+	require.NoError(t, err)
+
+	var user entity.User
+	require.NoError(t, c.Decode(legacy, &user))
+	assert.Equal(t, "ivan@example.com", user.Email)
+}
+
+func TestCodec_PlainJSONReadableByOldReplicas(t *testing.T) {
+	// Реплики до появления кодеков делают json.Unmarshal без разбора заголовка
+	c, err := New(FormatJSON, 0)
+	require.NoError(t, err)
+
+	data, err := c.Encode(testUser())
+	require.NoError(t, err)
+
+	var user entity.User
+	require.NoError(t, json.Unmarshal(data, &user))
+	assert.Equal(t, "ivan@example.com", user.Email)
+}
+
+func TestCodec_CorruptValueIsUnknown(t *testing.T) {
+	c, err := New(FormatJSON, 0)
+	require.NoError(t, err)
+
+	var user entity.User
+	assert.ErrorIs(t, c.Decode([]byte("{broken"), &user), ErrUnknownFormat)
+	assert.ErrorIs(t, c.Decode([]byte{magic, headerVersion, 1, flagGzip, 1, 2, 3}, &user), ErrUnknownFormat)
+	assert.ErrorIs(t, c.Decode([]byte{magic, headerVersion, 3, 0, 0xff, 0xff}, &user), ErrUnknownFormat)
+}
+
+func TestCodec_UnknownFormat(t *testing.T) {
+	c, err := New(FormatJSON, 0)
+	require.NoError(t, err)
+
+	var user entity.User
+	assert.ErrorIs(t, c.Decode([]byte{magic, headerVersion, 42, 0, '{', '}'}, &user), ErrUnknownFormat)
+	assert.ErrorIs(t, c.Decode([]byte{magic, headerVersion + 1, 1, 0, '{', '}'}, &user), ErrUnknownFormat)
+	assert.ErrorIs(t, c.Decode([]byte{magic, headerVersion, 1, 0x80, '{', '}'}, &user), ErrUnknownFormat)
+
+	_, err = New("xml", 0)
+	assert.Error(t, err)
+}
+
+func TestProtobuf_Unsupported(t *testing.T) {
+	c, err := New(FormatProtobuf, 0)
+	require.NoError(t, err)
+
+	_, err = c.Encode(&entity.Product{})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -0,0 +1,15 @@
+package codec
+
+import "encoding/json"
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) id() byte { return 1 }
+
+func (jsonSerializer) marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonSerializer) unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,158 @@
+package codec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/cachev1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protobufSerializer самый компактный формат, но знает только сущности, которые лежат в кеше
+type protobufSerializer struct{}
+
+func (protobufSerializer) id() byte { return 3 }
+
+func (protobufSerializer) marshal(v any) ([]byte, error) {
+	var msg proto.Message
+	switch v := v.(type) {
+	case *entity.User:
+		msg = userToProto(v)
+	case *entity.Order:
+		msg = orderToProto(v)
+	case *entity.OrderPage:
+		msg = orderPageToProto(v)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (protobufSerializer) unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *entity.User:
+		var msg cachev1.User
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		*v = userFromProto(&msg)
+	case *entity.Order:
+		var msg cachev1.Order
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		*v = orderFromProto(&msg)
+	case *entity.OrderPage:
+		var msg cachev1.OrderPage
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		v.NextCursor = msg.GetNextCursor()
+		v.Orders = make([]entity.Order, 0, len(msg.GetOrders()))
+		for _, order := range msg.GetOrders() {
+			v.Orders = append(v.Orders, orderFromProto(order))
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	return nil
+}
+
+func userToProto(u *entity.User) *cachev1.User {
+	return &cachev1.User{
+		Id:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Role:      u.Role,
+		Age:       int32(u.Age),
+		CreatedAt: timestamp(u.CreatedAt),
+		UpdatedAt: timestamp(u.UpdatedAt),
+	}
+}
+
+func userFromProto(m *cachev1.User) entity.User {
+	return entity.User{
+		ID:        m.GetId(),
+		FirstName: m.GetFirstName(),
+		LastName:  m.GetLastName(),
+		Email:     m.GetEmail(),
+		Role:      m.GetRole(),
+		Age:       int(m.GetAge()),
+		CreatedAt: fromTimestamp(m.GetCreatedAt()),
+		UpdatedAt: fromTimestamp(m.GetUpdatedAt()),
+	}
+}
+
+func orderToProto(o *entity.Order) *cachev1.Order {
+	items := make([]*cachev1.OrderItem, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, &cachev1.OrderItem{
+			Id:        item.ID,
+			OrderId:   item.OrderID,
+			ProductId: item.ProductID,
+			Name:      item.Name,
+			Quantity:  int32(item.Quantity),
+			Price:     item.Price,
+		})
+	}
+	return &cachev1.Order{
+		Id:        o.ID,
+		UserId:    o.UserID,
+		Status:    string(o.Status),
+		Items:     items,
+		Total:     o.Total,
+		CreatedAt: timestamp(o.CreatedAt),
+		UpdatedAt: timestamp(o.UpdatedAt),
+	}
+}
+
+func orderFromProto(m *cachev1.Order) entity.Order {
+	// JSON отдаёт пустой список позиций как [], сохраняем это и здесь
+	items := make([]entity.OrderItem, 0, len(m.GetItems()))
+	for _, item := range m.GetItems() {
+		items = append(items, entity.OrderItem{
+			ID:        item.GetId(),
+			OrderID:   item.GetOrderId(),
+			ProductID: item.GetProductId(),
+			Name:      item.GetName(),
+			Quantity:  int(item.GetQuantity()),
+			Price:     item.GetPrice(),
+		})
+	}
+	return entity.Order{
+		ID:        m.GetId(),
+		UserID:    m.GetUserId(),
+		Status:    entity.OrderStatus(m.GetStatus()),
+		Items:     items,
+		Total:     m.GetTotal(),
+		CreatedAt: fromTimestamp(m.GetCreatedAt()),
+		UpdatedAt: fromTimestamp(m.GetUpdatedAt()),
+	}
+}
+
+func orderPageToProto(p *entity.OrderPage) *cachev1.OrderPage {
+	orders := make([]*cachev1.Order, 0, len(p.Orders))
+	for i := range p.Orders {
+		orders = append(orders, orderToProto(&p.Orders[i]))
+	}
+	return &cachev1.OrderPage{Orders: orders, NextCursor: p.NextCursor}
+}
+
+// Нулевое время не пишем, иначе после чтения оно перестанет быть IsZero
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationChannel канал Redis, по которому реплики сообщают друг другу об удалённых ключах
+const InvalidationChannel = "commerce:v1:cache:invalidate"
+
+// evicter локальный уровень, который умеет забыть ключ или всё сразу
+type evicter interface {
+	remove(key string)
+	purge()
+}
+
+// Bus рассылает инвалидации через Redis pub/sub и применяет чужие к локальным уровням
+type Bus struct {
+	client  *redis.Client
+	breaker *Breaker
+	logger  *slog.Logger
+	locals  []evicter
+}
+
+// NewBus breaker общий с кешами: пока Redis лежит, запись не ждёт таймаута публикации. nil отключает проверку
+func NewBus(client *redis.Client, breaker *Breaker, logger *slog.Logger) *Bus {
+	return &Bus{client: client, breaker: breaker, logger: logger}
+}
+
+// register вызывается конструкторами уровней до Run
+func (b *Bus) register(l evicter) {
+	b.locals = append(b.locals, l)
+}
+
+// Publish сообщает остальным репликам, что ключ удалён. Своё сообщение тоже вернётся, это безвредно.
+// При открытом breaker сообщение не отправляется: чужие локальные копии доживут свой TTL
+func (b *Bus) Publish(ctx context.Context, key string) error {
+	if b.breaker == nil {
+		return b.client.Publish(ctx, InvalidationChannel, key).Err()
+	}
+	return exec(b.breaker, func() error {
+		return b.client.Publish(ctx, InvalidationChannel, key).Err()
+	})
+}
+
+// Run слушает канал до отмены ctx. После каждой (пере)подписки локальные уровни очищаются:
+// пока соединения не было, сообщения могли потеряться
+func (b *Bus) Run(ctx context.Context) {
+	pubsub := b.client.Subscribe(ctx, InvalidationChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				if m.Kind == "subscribe" {
+					b.logger.Debug("Cache invalidation subscribed, purging local cache")
+					b.purge()
+				}
+			case *redis.Message:
+				b.remove(m.Payload)
+			}
+		}
+	}
+}
+
+func (b *Bus) remove(key string) {
+	for _, l := range b.locals {
+		l.remove(key)
+	}
+}
+
+func (b *Bus) purge() {
+	for _, l := range b.locals {
+		l.purge()
+	}
+}
@@ -0,0 +1,55 @@
+// Package cache локальный уровень кеша в памяти процесса перед Redis
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// local LRU с TTL. nil значение это закешированный промах.
+// Значения копируются на входе и выходе, чтобы вызывающий не испортил запись для остальных
+type local[V any] struct {
+	entries *expirable.LRU[string, *V]
+	clone   func(*V) *V
+	// generation растёт на каждой инвалидации. Чтение, начатое до неё, не кладёт результат
+	// в память: иначе значение из Redis, прочитанное до записи, пережило бы DEL
+	generation atomic.Uint64
+}
+
+func newLocal[V any](size int, ttl time.Duration, clone func(*V) *V) *local[V] {
+	return &local[V]{
+		entries: expirable.NewLRU[string, *V](size, nil, ttl),
+		clone:   clone,
+	}
+}
+
+func (l *local[V]) get(key string) (value *V, ok bool) {
+	value, ok = l.entries.Get(key)
+	if !ok || value == nil {
+		return nil, ok
+	}
+	return l.clone(value), true
+}
+
+// add кладёт значение, если с начала чтения (gen) не было инвалидаций
+func (l *local[V]) add(gen uint64, key string, value *V) {
+	if l.generation.Load() != gen {
+		return
+	}
+	if value != nil {
+		value = l.clone(value)
+	}
+	l.entries.Add(key, value)
+}
+
+func (l *local[V]) remove(key string) {
+	l.generation.Add(1)
+	l.entries.Remove(key)
+}
+
+func (l *local[V]) purge() {
+	l.generation.Add(1)
+	l.entries.Purge()
+}
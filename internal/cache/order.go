@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+)
+
+type orderCache struct {
+	next  services.Cache
+	local *local[entity.Order]
+	bus   *Bus
+}
+
+// NewOrderCache то же, что NewUserCache, для заказов. Страницы списков в памяти не держим:
+// они меняются с каждым заказом пользователя, их читаем прямо из Redis
+func NewOrderCache(next services.Cache, bus *Bus, size int, ttl time.Duration) services.Cache {
+	c := &orderCache{
+		next:  next,
+		local: newLocal(size, ttl, cloneOrder),
+		bus:   bus,
+	}
+	if bus != nil {
+		bus.register(c.local)
+	}
+	return c
+}
+
+func (c *orderCache) Get(ctx context.Context, key string) (*entity.Order, error) {
+	if order, ok := c.local.get(key); ok {
+		if order == nil {
+			return nil, repositories.ErrOrderNotFound
+		}
+		return order, nil
+	}
+
+	gen := c.local.generation.Load()
+	order, err := c.next.Get(ctx, key)
+	switch {
+	case err == nil && order != nil:
+		c.local.add(gen, key, order)
+	case errors.Is(err, repositories.ErrOrderNotFound):
+		c.local.add(gen, key, nil)
+	}
+	return order, err
+}
+
+func (c *orderCache) Set(ctx context.Context, key string, order *entity.Order, ttl time.Duration) error {
+	gen := c.local.generation.Load()
+	err := c.next.Set(ctx, key, order, ttl)
+
+	if order == nil {
+		c.local.remove(key)
+		publish(ctx, c.bus, key)
+		return err
+	}
+	if err == nil {
+		c.local.add(gen, key, order)
+	}
+	return err
+}
+
+func (c *orderCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	gen := c.local.generation.Load()
+	err := c.next.SetNotFound(ctx, key, ttl)
+	if err == nil {
+		c.local.add(gen, key, nil)
+	}
+	return err
+}
+
+func (c *orderCache) GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error) {
+	return c.next.GetPage(ctx, key, field)
+}
+
+func (c *orderCache) SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error {
+	return c.next.SetPage(ctx, key, field, page, ttl)
+}
+
+func cloneOrder(order *entity.Order) *entity.Order {
+	clone := *order
+	clone.Items = slices.Clone(order.Items)
+	return &clone
+}
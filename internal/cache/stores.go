@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+)
+
+// IdempotencyStore то же, что handlers.IdempotencyStore. Объявлен здесь, потому что handlers
+// сам зависит от этого пакета через ErrUnavailable
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (*repositories.IdempotencyRecord, bool, error)
+	Extend(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) error
+	Complete(ctx context.Context, key, owner string, record *repositories.IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key, fingerprint, owner string) error
+}
+
+type protectedIdempotencyStore struct {
+	next    IdempotencyStore
+	breaker *Breaker
+}
+
+// ProtectIdempotencyStore пропускает ключи идемпотентности через breaker
+func ProtectIdempotencyStore(next IdempotencyStore, breaker *Breaker) IdempotencyStore {
+	return &protectedIdempotencyStore{next: next, breaker: breaker}
+}
+
+type beginResult struct {
+	record   *repositories.IdempotencyRecord
+	acquired bool
+}
+
+func (s *protectedIdempotencyStore) Begin(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (*repositories.IdempotencyRecord, bool, error) {
+	res, err := call(s.breaker, func() (beginResult, error) {
+		record, acquired, err := s.next.Begin(ctx, key, fingerprint, owner, lockTTL)
+		return beginResult{record: record, acquired: acquired}, err
+	})
+	return res.record, res.acquired, err
+}
+
+func (s *protectedIdempotencyStore) Extend(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.Extend(ctx, key, fingerprint, owner, lockTTL) })
+}
+
+func (s *protectedIdempotencyStore) Complete(ctx context.Context, key, owner string, record *repositories.IdempotencyRecord, ttl time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.Complete(ctx, key, owner, record, ttl) })
+}
+
+func (s *protectedIdempotencyStore) Release(ctx context.Context, key, fingerprint, owner string) error {
+	return exec(s.breaker, func() error { return s.next.Release(ctx, key, fingerprint, owner) })
+}
+
+type protectedRefreshTokenStore struct {
+	next    services.RefreshTokenStore
+	breaker *Breaker
+}
+
+// ProtectRefreshTokenStore пропускает refresh токены через breaker
+func ProtectRefreshTokenStore(next services.RefreshTokenStore, breaker *Breaker) services.RefreshTokenStore {
+	return &protectedRefreshTokenStore{next: next, breaker: breaker}
+}
+
+func (s *protectedRefreshTokenStore) Save(ctx context.Context, token string, userID int64, ttl time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.Save(ctx, token, userID, ttl) })
+}
+
+func (s *protectedRefreshTokenStore) Consume(ctx context.Context, token string) (int64, error) {
+	return call(s.breaker, func() (int64, error) { return s.next.Consume(ctx, token) })
+}
+
+func (s *protectedRefreshTokenStore) Delete(ctx context.Context, token string) error {
+	return exec(s.breaker, func() error { return s.next.Delete(ctx, token) })
+}
+
+type protectedCartStore struct {
+	next    services.CartStore
+	breaker *Breaker
+}
+
+// ProtectCartStore пропускает корзины через breaker
+func ProtectCartStore(next services.CartStore, breaker *Breaker) services.CartStore {
+	return &protectedCartStore{next: next, breaker: breaker}
+}
+
+func (s *protectedCartStore) Items(ctx context.Context, key string) (map[int64]int, error) {
+	return call(s.breaker, func() (map[int64]int, error) { return s.next.Items(ctx, key) })
+}
+
+func (s *protectedCartStore) Add(ctx context.Context, key string, productID int64, quantity, limit int, ttl time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.Add(ctx, key, productID, quantity, limit, ttl) })
+}
+
+func (s *protectedCartStore) SetQuantity(ctx context.Context, key string, productID int64, quantity int, ttl time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.SetQuantity(ctx, key, productID, quantity, ttl) })
+}
+
+func (s *protectedCartStore) Remove(ctx context.Context, key string, productID int64) error {
+	return exec(s.breaker, func() error { return s.next.Remove(ctx, key, productID) })
+}
+
+func (s *protectedCartStore) Claim(ctx context.Context, key string) (map[int64]int, error) {
+	return call(s.breaker, func() (map[int64]int, error) { return s.next.Claim(ctx, key) })
+}
+
+func (s *protectedCartStore) Restore(ctx context.Context, key string, items map[int64]int, limit int, ttl time.Duration) error {
+	return exec(s.breaker, func() error { return s.next.Restore(ctx, key, items, limit, ttl) })
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+)
+
+type userCache struct {
+	next  services.UserCache
+	local *local[entity.User]
+	bus   *Bus
+}
+
+// NewUserCache ставит LRU в памяти перед next. Инвалидации уходят в bus, bus может быть nil,
+// если реплика одна
+func NewUserCache(next services.UserCache, bus *Bus, size int, ttl time.Duration) services.UserCache {
+	c := &userCache{
+		next:  next,
+		local: newLocal(size, ttl, cloneUser),
+		bus:   bus,
+	}
+	if bus != nil {
+		bus.register(c.local)
+	}
+	return c
+}
+
+func (c *userCache) Get(ctx context.Context, key string) (*entity.User, error) {
+	if user, ok := c.local.get(key); ok {
+		if user == nil {
+			return nil, repositories.ErrUserNotFound
+		}
+		return user, nil
+	}
+
+	gen := c.local.generation.Load()
+	user, err := c.next.Get(ctx, key)
+	switch {
+	case err == nil && user != nil:
+		c.local.add(gen, key, user)
+	case errors.Is(err, repositories.ErrUserNotFound):
+		c.local.add(gen, key, nil)
+	}
+	return user, err
+}
+
+// Set с nil это инвалидация: удаляем у себя, в Redis и сообщаем остальным репликам
+func (c *userCache) Set(ctx context.Context, key string, user *entity.User, ttl time.Duration) error {
+	gen := c.local.generation.Load()
+	err := c.next.Set(ctx, key, user, ttl)
+
+	if user == nil {
+		c.local.remove(key)
+		publish(ctx, c.bus, key)
+		return err
+	}
+	if err == nil {
+		c.local.add(gen, key, user)
+	}
+	return err
+}
+
+func (c *userCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	gen := c.local.generation.Load()
+	err := c.next.SetNotFound(ctx, key, ttl)
+	if err == nil {
+		c.local.add(gen, key, nil)
+	}
+	return err
+}
+
+// cloneUser повторяет то, что происходит при записи в Redis: хеш пароля в кеш не попадает
+func cloneUser(user *entity.User) *entity.User {
+	clone := *user
+	clone.PasswordHash = ""
+	return &clone
+}
+
+func publish(ctx context.Context, bus *Bus, key string) {
+	if bus == nil {
+		return
+	}
+	if err := bus.Publish(ctx, key); err != nil && !errors.Is(err, ErrUnavailable) {
+		logging.FromContext(ctx).WarnContext(ctx, "Failed to publish cache invalidation",
+			slog.String("key", key), slog.Any("error", err))
+	}
+}
@@ -2,7 +2,11 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -16,29 +20,107 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration // 0 значит без ограничения
+	ConnMaxIdleTime time.Duration
+
+	ConnectAttempts int           // сколько раз пробуем подключиться при старте
+	ConnectBackoff  time.Duration // пауза перед второй попыткой, дальше удваивается
+	MaxBackoff      time.Duration
+	PingTimeout     time.Duration // таймаут одной попытки
 }
 
-func NewPostgres(cfg Config) (*sqlx.DB, error) {
-	dsn := fmt.Sprintf(
+func (c Config) DSN() string {
+	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
 	)
+}
 
-	db, err := sqlx.Open("postgres", dsn)
+// NewPostgres открывает пул с настройками из конфига и ждёт, пока база ответит.
+// Между попытками экспоненциальная пауза с jitter, чтобы реплики не ломились разом
+func NewPostgres(ctx context.Context, cfg Config, logger *slog.Logger) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Second)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	attempts := max(cfg.ConnectAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		logger.Info("Attempting to connect to PostgreSQL", slog.Int("attempt", attempt))
 
-	if err := db.PingContext(ctx); err != nil {
-		return nil, err
+		err = ping(ctx, db, cfg.PingTimeout)
+		if err == nil {
+			return db, nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		delay := backoff(cfg.ConnectBackoff, cfg.MaxBackoff, attempt)
+		logger.Warn("Postgres not ready yet", slog.Any("error", err), slog.Duration("retry_in", delay))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		}
 	}
 
-	return db, nil
+	db.Close()
+	return nil, fmt.Errorf("postgres connection failed after %d attempts: %w", attempts, err)
+}
+
+func ping(ctx context.Context, db *sqlx.DB, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
+
+// backoff пауза перед попыткой attempt+1: base*2^(attempt-1), не больше limit,
+// из неё берётся случайная величина от половины до целого (equal jitter)
+func backoff(base, limit time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if limit > 0 {
+		delay = min(delay, limit)
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// StatsHandler отдаёт sql.DBStats пула в JSON для служебного порта
+func StatsHandler(db *sqlx.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		stats := db.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_idle_time_closed": stats.MaxIdleTimeClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		})
+	})
 }
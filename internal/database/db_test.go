@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	base, limit := 100*time.Millisecond, time.Second
+
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		for range 20 {
+			delay := backoff(base, limit, attempt)
+			assert.GreaterOrEqual(t, delay, want/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, delay, want, "attempt %d", attempt)
+		}
+	}
+
+	assert.Zero(t, backoff(0, limit, 3))
+}
+
+func TestNewPostgres_GivesUpAfterAttempts(t *testing.T) {
+	cfg := Config{
+		Host:            "127.0.0.1",
+		Port:            1,
+		User:            "postgres",
+		DBName:          "commerce",
+		SSLMode:         "disable",
+		ConnectAttempts: 2,
+		ConnectBackoff:  time.Millisecond,
+		MaxBackoff:      time.Millisecond,
+		PingTimeout:     time.Second,
+	}
+
+	db, err := NewPostgres(context.Background(), cfg, slog.New(slog.DiscardHandler))
+
+	assert.Nil(t, db)
+	assert.ErrorContains(t, err, "after 2 attempts")
+}
+
+func TestStatsHandler(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	db.SetMaxOpenConns(7)
+
+	rec := httptest.NewRecorder()
+	StatsHandler(db).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/db", nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, float64(7), body["max_open_connections"])
+}
@@ -0,0 +1,16 @@
+package entity
+
+// Cart корзина до оформления, цены в ней справочные и пересчитываются при checkout
+type Cart struct {
+	ID    string     `json:"id,omitempty"`
+	Items []CartItem `json:"items"`
+	Total int64      `json:"total"`
+}
+
+type CartItem struct {
+	ProductID int64  `json:"product_id"`
+	Name      string `json:"name"`
+	Price     int64  `json:"price"`
+	Quantity  int    `json:"quantity"`
+	Available bool   `json:"available"`
+}
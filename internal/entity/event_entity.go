@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+const (
+	AggregateOrder = "order"
+	AggregateUser  = "user"
+)
+
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderUpdated       = "order.updated"
+	EventOrderDeleted       = "order.deleted"
+	EventOrderStatusChanged = "order.status_changed"
+	EventUserCreated        = "user.created"
+	EventUserDeleted        = "user.deleted"
+)
+
+// Event доменное событие из outbox. Payload это JSON состояния на момент изменения,
+// храним байтами: database/sql копирует []byte при сканировании, а json.RawMessage нет
+type Event struct {
+	ID            int64     `json:"id" db:"id"`
+	AggregateType string    `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   int64     `json:"aggregate_id" db:"aggregate_id"`
+	Type          string    `json:"event_type" db:"event_type"`
+	Payload       []byte    `json:"-" db:"payload"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// OrderDeletedPayload после удаления заказа от него остаются только идентификаторы
+type OrderDeletedPayload struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+type OrderStatusChangedPayload struct {
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"user_id"`
+	From      OrderStatus `json:"from"`
+	To        OrderStatus `json:"to"`
+	ChangedBy int64       `json:"changed_by"`
+}
+
+type UserDeletedPayload struct {
+	ID int64 `json:"id"`
+}
@@ -2,19 +2,44 @@ package entity
 
 import "time"
 
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusShipped   OrderStatus = "shipped"
+	OrderStatusDelivered OrderStatus = "delivered"
+	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusRefunded  OrderStatus = "refunded"
+)
+
 type Order struct {
 	ID        int64       `json:"id" db:"id"`
 	UserID    int64       `json:"user_id" db:"user_id"`
+	Status    OrderStatus `json:"status" db:"status"`
 	Items     []OrderItem `json:"items" db:"-"`
 	Total     int64       `json:"total" db:"total"`
 	CreatedAt time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
 }
 
+// OrderItem позиция заказа. Клиент передаёт product_id и quantity,
+// name и price сервер берёт из каталога и сохраняет как снимок на момент заказа
 type OrderItem struct {
-	ID       int64  `json:"id" db:"id"`
-	OrderID  int64  `json:"order_id" db:"order_id"`
-	Name     string `json:"name" db:"name"`
-	Quantity int    `json:"quantity" db:"quantity"`
-	Price    int64  `json:"price" db:"price"`
+	ID        int64  `json:"id" db:"id"`
+	OrderID   int64  `json:"order_id" db:"order_id"`
+	ProductID int64  `json:"product_id" db:"product_id"`
+	Name      string `json:"name" db:"name"`
+	Quantity  int    `json:"quantity" db:"quantity"`
+	Price     int64  `json:"price" db:"price"`
+}
+
+// OrderStatusChange запись истории: кто и когда перевёл заказ в другой статус
+type OrderStatusChange struct {
+	ID         int64       `json:"id" db:"id"`
+	OrderID    int64       `json:"order_id" db:"order_id"`
+	FromStatus OrderStatus `json:"from_status" db:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" db:"to_status"`
+	ChangedBy  *int64      `json:"changed_by" db:"changed_by"`
+	ChangedAt  time.Time   `json:"changed_at" db:"changed_at"`
 }
@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid order filter")
+)
+
+const (
+	OrderSortCreatedAt = "created_at"
+	OrderSortTotal     = "total"
+)
+
+// OrderPage одна страница истории заказов, NextCursor пустой на последней странице
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// OrderCursor позиция keyset пагинации: значения сортируемых колонок последнего заказа страницы
+type OrderCursor struct {
+	CreatedAt time.Time
+	Total     int64
+	ID        int64
+}
+
+func CursorAfter(order Order) OrderCursor {
+	return OrderCursor{CreatedAt: order.CreatedAt, Total: order.Total, ID: order.ID}
+}
+
+func (c OrderCursor) Encode() string {
+	raw := strings.Join([]string{
+		strconv.FormatInt(c.CreatedAt.UnixNano(), 10),
+		strconv.FormatInt(c.Total, 10),
+		strconv.FormatInt(c.ID, 10),
+	}, ":")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeOrderCursor(s string) (*OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]int64, len(parts))
+	for i, part := range parts {
+		values[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return &OrderCursor{CreatedAt: time.Unix(0, values[0]).UTC(), Total: values[1], ID: values[2]}, nil
+}
+
+// OrderFilter условия выборки для общего списка заказов, nil поля не фильтруют
+type OrderFilter struct {
+	UserID      *int64
+	MinTotal    *int64
+	MaxTotal    *int64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	ItemName    string
+
+	SortBy string // OrderSortCreatedAt или OrderSortTotal
+	Desc   bool
+
+	After *OrderCursor
+	Limit int
+}
+
+func (f *OrderFilter) Validate() error {
+	switch f.SortBy {
+	case "":
+		f.SortBy = OrderSortCreatedAt
+	case OrderSortCreatedAt, OrderSortTotal:
+	default:
+		return ErrInvalidFilter
+	}
+
+	if f.MinTotal != nil && f.MaxTotal != nil && *f.MinTotal > *f.MaxTotal {
+		return ErrInvalidFilter
+	}
+	if f.CreatedFrom != nil && f.CreatedTo != nil && f.CreatedFrom.After(*f.CreatedTo) {
+		return ErrInvalidFilter
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSKU         = errors.New("invalid sku")
+	ErrInvalidProductName = errors.New("invalid product name")
+	ErrInvalidPrice       = errors.New("invalid price")
+	ErrInvalidStock       = errors.New("invalid stock")
+)
+
+type Product struct {
+	ID          int64     `json:"id" db:"id"`
+	SKU         string    `json:"sku" db:"sku" binding:"required"`
+	Name        string    `json:"name" db:"name" binding:"required"`
+	Description string    `json:"description" db:"description"`
+	Price       int64     `json:"price" db:"price"`
+	Active      bool      `json:"active" db:"active"`
+	Stock       int       `json:"stock" db:"stock"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (p *Product) Validate() error {
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+
+	if p.SKU == "" || len(p.SKU) > 64 {
+		return ErrInvalidSKU
+	}
+	if p.Name == "" {
+		return ErrInvalidProductName
+	}
+	if p.Price < 0 {
+		return ErrInvalidPrice
+	}
+	if p.Stock < 0 {
+		return ErrInvalidStock
+	}
+
+	return nil
+}
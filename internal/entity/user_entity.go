@@ -19,6 +19,7 @@ type User struct {
 	LastName     string    `json:"last_name" db:"last_name" binding:"required"`
 	Email        string    `json:"email" db:"email" binding:"required,email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         string    `json:"role" db:"role"`
 	Age          int       `json:"age" db:"age"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
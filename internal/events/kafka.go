@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/eventsv1"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Encoding формат тела сообщения
+type Encoding string
+
+const (
+	EncodingJSON     Encoding = "json"
+	EncodingProtobuf Encoding = "protobuf"
+)
+
+const (
+	HeaderEventID     = "event_id"
+	HeaderEventType   = "event_type"
+	HeaderContentType = "content_type"
+)
+
+// MessageWriter то, что умеет kafka.Writer. Через него же подключается MemoryBroker в тестах
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaBatchTimeout relay пишет события по одному и ждёт ответа, поэтому копить пачку
+// дольше нет смысла: со стандартной секундой outbox разгребался бы по событию в секунду
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+// NewKafkaWriter пишет в кластер. Партицию выбирает murmur2 от ключа, как у стандартного Java клиента,
+// поэтому события одного агрегата всегда попадают в одну партицию и сохраняют порядок
+func NewKafkaWriter(brokers []string, writeTimeout time.Duration) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Murmur2Balancer{},
+		BatchTimeout:           kafkaBatchTimeout,
+		RequiredAcks:           kafka.RequireAll,
+		WriteTimeout:           writeTimeout,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+// envelope JSON вариант eventsv1.Event
+type envelope struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+type KafkaPublisher struct {
+	writer      MessageWriter
+	topicPrefix string
+	encoding    Encoding
+}
+
+func NewKafkaPublisher(writer MessageWriter, topicPrefix string, encoding Encoding) (*KafkaPublisher, error) {
+	if encoding != EncodingJSON && encoding != EncodingProtobuf {
+		return nil, fmt.Errorf("unknown event encoding %q", encoding)
+	}
+	return &KafkaPublisher{
+		writer:      writer,
+		topicPrefix: topicPrefix,
+		encoding:    encoding,
+	}, nil
+}
+
+// Topic у каждого типа агрегата свой топик: commerce.order, commerce.user
+func (p *KafkaPublisher) Topic(aggregateType string) string {
+	return p.topicPrefix + "." + aggregateType
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event entity.Event) error {
+	value, err := p.encode(event)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic: p.Topic(event.AggregateType),
+		Key:   []byte(strconv.FormatInt(event.AggregateID, 10)),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderEventID, Value: []byte(strconv.FormatInt(event.ID, 10))},
+			{Key: HeaderEventType, Value: []byte(event.Type)},
+			{Key: HeaderContentType, Value: []byte(p.contentType())},
+		},
+		Time: event.CreatedAt,
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+func (p *KafkaPublisher) contentType() string {
+	if p.encoding == EncodingProtobuf {
+		return "application/x-protobuf"
+	}
+	return "application/json"
+}
+
+func (p *KafkaPublisher) encode(event entity.Event) ([]byte, error) {
+	if p.encoding == EncodingJSON {
+		return json.Marshal(envelope{
+			ID:            event.ID,
+			AggregateType: event.AggregateType,
+			AggregateID:   event.AggregateID,
+			EventType:     event.Type,
+			CreatedAt:     event.CreatedAt,
+			Payload:       event.Payload,
+		})
+	}
+
+	payload := &structpb.Struct{}
+	if err := payload.UnmarshalJSON(event.Payload); err != nil {
+		return nil, fmt.Errorf("event %d payload: %w", event.ID, err)
+	}
+
+	return proto.Marshal(&eventsv1.Event{
+		Id:            event.ID,
+		AggregateType: event.AggregateType,
+		AggregateId:   event.AggregateID,
+		EventType:     event.Type,
+		CreatedAt:     timestamppb.New(event.CreatedAt),
+		Payload:       payload,
+	})
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/eventsv1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func orderEvent(id, orderID int64, eventType string) entity.Event {
+	return entity.Event{
+		ID:            id,
+		AggregateType: entity.AggregateOrder,
+		AggregateID:   orderID,
+		Type:          eventType,
+		Payload:       []byte(fmt.Sprintf(`{"id":%d,"total":350}`, orderID)),
+		CreatedAt:     time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestKafkaPublisher_JSON(t *testing.T) {
+	broker := NewMemoryBroker(4)
+	publisher, err := NewKafkaPublisher(broker, "commerce", EncodingJSON)
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.Publish(context.Background(), orderEvent(1, 5, entity.EventOrderCreated)))
+
+	msgs := broker.Messages("commerce.order")
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "5", string(msgs[0].Key))
+	assert.JSONEq(t, `{
+		"id": 1,
+		"aggregate_type": "order",
+		"aggregate_id": 5,
+		"event_type": "order.created",
+		"created_at": "2025-01-01T12:00:00Z",
+		"payload": {"id": 5, "total": 350}
+	}`, string(msgs[0].Value))
+}
+
+func TestKafkaPublisher_Protobuf(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	publisher, err := NewKafkaPublisher(broker, "commerce", EncodingProtobuf)
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.Publish(context.Background(), orderEvent(1, 5, entity.EventOrderCreated)))
+
+	msgs := broker.Messages("commerce.order")
+	require.Len(t, msgs, 1)
+
+	var decoded eventsv1.Event
+	require.NoError(t, proto.Unmarshal(msgs[0].Value, &decoded))
+	assert.Equal(t, int64(5), decoded.GetAggregateId())
+	assert.Equal(t, entity.EventOrderCreated, decoded.GetEventType())
+	assert.Equal(t, float64(350), decoded.GetPayload().GetFields()["total"].GetNumberValue())
+}
+
+func TestKafkaPublisher_UnknownEncoding(t *testing.T) {
+	_, err := NewKafkaPublisher(NewMemoryBroker(1), "commerce", Encoding("xml"))
+
+	assert.Error(t, err)
+}
+
+func TestRelay_KeyedOrderingThroughBroker(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(8)
+	publisher, err := NewKafkaPublisher(broker, "commerce", EncodingJSON)
+	require.NoError(t, err)
+
+	// События одного заказа вперемешку с другими должны лечь в одну партицию по порядку
+	batch := []entity.Event{
+		orderEvent(1, 5, entity.EventOrderCreated),
+		orderEvent(2, 6, entity.EventOrderCreated),
+		orderEvent(3, 5, entity.EventOrderUpdated),
+		orderEvent(4, 5, entity.EventOrderDeleted),
+	}
+	store := new(MockOutboxStore)
+	store.On("Claim", ctx, 10, claimLease).Return(batch, nil)
+	store.On("MarkPublished", ctx, mock.Anything).Return(nil)
+
+	_, err = NewRelay(store, publisher, slog.New(slog.DiscardHandler), time.Second, 10, 3).ProcessBatch(ctx)
+	require.NoError(t, err)
+
+	partition := -1
+	var types []string
+	for _, msg := range broker.Messages("commerce.order") {
+		if string(msg.Key) != "5" {
+			continue
+		}
+		if partition == -1 {
+			partition = msg.Partition
+		}
+		assert.Equal(t, partition, msg.Partition)
+		types = append(types, string(msg.Headers[1].Value))
+	}
+	assert.Equal(t, []string{entity.EventOrderCreated, entity.EventOrderUpdated, entity.EventOrderDeleted}, types)
+	store.AssertNumberOfCalls(t, "MarkPublished", 4)
+}
+
+func TestRelay_RetriesWhenBrokerUnavailable(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(1)
+	broker.FailNext(1)
+	publisher, err := NewKafkaPublisher(broker, "commerce", EncodingJSON)
+	require.NoError(t, err)
+
+	event := orderEvent(1, 5, entity.EventOrderCreated)
+	event.Attempts = 1
+	store := new(MockOutboxStore)
+	store.On("Claim", ctx, 10, claimLease).Return([]entity.Event{event}, nil).Once()
+	store.On("MarkFailed", ctx, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+
+	relay := NewRelay(store, publisher, slog.New(slog.DiscardHandler), time.Second, 10, 3)
+	_, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, broker.Messages("commerce.order"))
+
+	// Следующая попытка проходит, брокер снова доступен
+	event.Attempts = 2
+	store.On("Claim", ctx, 10, claimLease).Return([]entity.Event{event}, nil).Once()
+	store.On("MarkPublished", ctx, int64(1)).Return(nil).Once()
+
+	_, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Len(t, broker.Messages("commerce.order"), 1)
+	store.AssertExpectations(t)
+}
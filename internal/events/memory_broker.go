@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var ErrBrokerClosed = errors.New("broker is closed")
+
+// MemoryBroker брокер в памяти процесса для тестов без Kafka. Раскладывает сообщения
+// по партициям тем же хешем, что и NewKafkaWriter, так что порядок по ключу проверяется честно
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string][][]kafka.Message
+	balancer   kafka.Balancer
+	closed     bool
+	failNext   int
+}
+
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]kafka.Message),
+		balancer:   &kafka.Murmur2Balancer{},
+	}
+}
+
+func (b *MemoryBroker) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	if b.failNext > 0 {
+		b.failNext--
+		return kafka.LeaderNotAvailable
+	}
+
+	ids := make([]int, b.partitions)
+	for i := range ids {
+		ids[i] = i
+	}
+
+	for _, msg := range msgs {
+		partitions, ok := b.topics[msg.Topic]
+		if !ok {
+			partitions = make([][]kafka.Message, b.partitions)
+			b.topics[msg.Topic] = partitions
+		}
+
+		p := b.balancer.Balance(msg, ids...)
+		msg.Partition = p
+		msg.Offset = int64(len(partitions[p]))
+		partitions[p] = append(partitions[p], msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	return nil
+}
+
+// FailNext следующие n вызовов WriteMessages вернут ошибку, чтобы проверять ретраи relay
+func (b *MemoryBroker) FailNext(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failNext = n
+}
+
+// Partition копия сообщений одной партиции в порядке записи
+func (b *MemoryBroker) Partition(topic string, partition int) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions, ok := b.topics[topic]
+	if !ok || partition < 0 || partition >= len(partitions) {
+		return nil
+	}
+	return append([]kafka.Message(nil), partitions[partition]...)
+}
+
+// Messages все сообщения топика, партиция за партицией
+func (b *MemoryBroker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var all []kafka.Message
+	for _, partition := range b.topics[topic] {
+		all = append(all, partition...)
+	}
+	return all
+}
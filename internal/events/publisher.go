@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+)
+
+// EventPublisher доставляет событие во внешнюю систему. Доставка как минимум один раз:
+// одно и то же событие может прийти повторно, потребители дедуплицируют по Event.ID
+type EventPublisher interface {
+	Publish(ctx context.Context, event entity.Event) error
+}
+
+// LogPublisher пишет события в лог, пока нет брокера
+type LogPublisher struct {
+	logger *slog.Logger
+}
+
+func NewLogPublisher(logger *slog.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event entity.Event) error {
+	p.logger.InfoContext(ctx, "event",
+		slog.Int64("event_id", event.ID),
+		slog.String("event_type", event.Type),
+		slog.String("aggregate_type", event.AggregateType),
+		slog.Int64("aggregate_id", event.AggregateID),
+		slog.String("payload", string(event.Payload)),
+	)
+	return nil
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+)
+
+type OutboxStore interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]entity.Event, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	MarkDead(ctx context.Context, id int64, reason string) error
+}
+
+const (
+	DefaultBatchSize   = 100
+	DefaultMaxAttempts = 10
+
+	// lease должен быть заметно больше времени отправки пачки, иначе событие уйдёт дважды
+	claimLease = time.Minute
+	maxBackoff = 10 * time.Minute
+)
+
+// Relay перекладывает события из outbox в EventPublisher
+type Relay struct {
+	store       OutboxStore
+	publisher   EventPublisher
+	logger      *slog.Logger
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+}
+
+func NewRelay(store OutboxStore, publisher EventPublisher, logger *slog.Logger, interval time.Duration, batchSize, maxAttempts int) *Relay {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		logger:      logger,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run разбирает outbox, пока не отменят ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// Полная пачка значит, что в очереди есть ещё, не ждём следующего тика
+		for {
+			n, err := r.ProcessBatch(ctx)
+			if err != nil {
+				r.logger.ErrorContext(ctx, "outbox relay batch failed", slog.Any("error", err))
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch отправляет одну пачку и возвращает, сколько событий было взято
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	batch, err := r.store.Claim(ctx, r.batchSize, claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range batch {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			r.fail(ctx, event, err)
+			continue
+		}
+		if err := r.store.MarkPublished(ctx, event.ID); err != nil {
+			// Событие уже ушло, после lease оно уйдёт ещё раз, это допустимо
+			r.logger.WarnContext(ctx, "outbox relay: mark event published", slog.Int64("event_id", event.ID), slog.Any("error", err))
+		}
+	}
+
+	return len(batch), nil
+}
+
+func (r *Relay) fail(ctx context.Context, event entity.Event, cause error) {
+	if event.Attempts >= r.maxAttempts {
+		r.logger.ErrorContext(ctx, "outbox relay: event is dead",
+			slog.Int64("event_id", event.ID),
+			slog.String("event_type", event.Type),
+			slog.Int("attempts", event.Attempts),
+			slog.Any("error", cause),
+		)
+		if err := r.store.MarkDead(ctx, event.ID, cause.Error()); err != nil {
+			r.logger.ErrorContext(ctx, "outbox relay: mark event dead", slog.Int64("event_id", event.ID), slog.Any("error", err))
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(backoff(event.Attempts))
+	if err := r.store.MarkFailed(ctx, event.ID, cause.Error(), retryAt); err != nil {
+		r.logger.ErrorContext(ctx, "outbox relay: mark event failed", slog.Int64("event_id", event.ID), slog.Any("error", err))
+	}
+}
+
+// backoff экспоненциальная задержка: 1s, 2s, 4s ... но не больше maxBackoff
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := time.Second << min(attempts-1, 20)
+	return min(delay, maxBackoff)
+}
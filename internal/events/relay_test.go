@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxStore struct{ mock.Mock }
+
+func (m *MockOutboxStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]entity.Event, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]entity.Event), args.Error(1)
+}
+
+func (m *MockOutboxStore) MarkPublished(ctx context.Context, id int64) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockOutboxStore) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	return m.Called(ctx, id, reason, retryAt).Error(0)
+}
+
+func (m *MockOutboxStore) MarkDead(ctx context.Context, id int64, reason string) error {
+	return m.Called(ctx, id, reason).Error(0)
+}
+
+type MockPublisher struct{ mock.Mock }
+
+func (m *MockPublisher) Publish(ctx context.Context, event entity.Event) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+func TestRelay_ProcessBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes and retries failures", func(t *testing.T) {
+		store, publisher := new(MockOutboxStore), new(MockPublisher)
+		relay := NewRelay(store, publisher, slog.New(slog.DiscardHandler), time.Second, 10, 3)
+
+		ok := entity.Event{ID: 1, Type: entity.EventOrderCreated, Attempts: 1}
+		failing := entity.Event{ID: 2, Type: entity.EventOrderUpdated, Attempts: 1}
+		store.On("Claim", ctx, 10, claimLease).Return([]entity.Event{ok, failing}, nil)
+		publisher.On("Publish", ctx, ok).Return(nil)
+		publisher.On("Publish", ctx, failing).Return(errors.New("broker down"))
+		store.On("MarkPublished", ctx, int64(1)).Return(nil)
+		store.On("MarkFailed", ctx, int64(2), "broker down", mock.MatchedBy(func(at time.Time) bool {
+			return at.After(time.Now())
+		})).Return(nil)
+
+		n, err := relay.ProcessBatch(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("dead letter after max attempts", func(t *testing.T) {
+		store, publisher := new(MockOutboxStore), new(MockPublisher)
+		relay := NewRelay(store, publisher, slog.New(slog.DiscardHandler), time.Second, 10, 3)
+
+		event := entity.Event{ID: 3, Attempts: 3}
+		store.On("Claim", ctx, 10, claimLease).Return([]entity.Event{event}, nil)
+		publisher.On("Publish", ctx, event).Return(errors.New("bad payload"))
+		store.On("MarkDead", ctx, int64(3), "bad payload").Return(nil)
+
+		_, err := relay.ProcessBatch(ctx)
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+		store.AssertNotCalled(t, "MarkFailed", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, maxBackoff, backoff(50))
+}
@@ -0,0 +1,56 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/pkg/token"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type AccessTokenParser interface {
+	ParseAccessToken(tokenStr string) (*token.Claims, error)
+}
+
+// AuthInterceptor достаёт пользователя из metadata authorization: Bearer <token>.
+// Методы из public доступны без токена, но переданный токен всё равно проверяется
+func AuthInterceptor(parser AccessTokenParser, public ...string) grpc.UnaryServerInterceptor {
+	open := make(map[string]bool, len(public))
+	for _, method := range public {
+		open[method] = true
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var header string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				header = values[0]
+			}
+		}
+
+		if header == "" {
+			if open[info.FullMethod] {
+				return handler(ctx, req)
+			}
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		tokenStr, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenStr == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		claims, err := parser.ParseAccessToken(tokenStr)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		identity := auth.Identity{UserID: claims.UserID, Role: claims.Role}
+		return handler(auth.WithIdentity(ctx, identity), req)
+	}
+}
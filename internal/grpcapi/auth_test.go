@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	manager := token.NewJWTManager("test-secret", time.Minute)
+	interceptor := AuthInterceptor(manager, "/svc/Public")
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		identity, ok := auth.FromContext(ctx)
+		if !ok {
+			return "anonymous", nil
+		}
+		return identity, nil
+	}
+
+	call := func(method, authorization string) (any, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		_, err := call("/svc/Private", "")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := call("/svc/Private", "Bearer garbage")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("public method without token", func(t *testing.T) {
+		resp, err := call("/svc/Public", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "anonymous", resp)
+	})
+
+	t.Run("public method with invalid token", func(t *testing.T) {
+		_, err := call("/svc/Public", "Bearer garbage")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		access, err := manager.NewAccessToken(42, auth.RoleAdmin)
+		assert.NoError(t, err)
+
+		resp, err := call("/svc/Private", "Bearer "+access)
+		assert.NoError(t, err)
+		assert.Equal(t, auth.Identity{UserID: 42, Role: auth.RoleAdmin}, resp)
+	})
+}
@@ -0,0 +1,89 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/commercev1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProtoUser(user *entity.User) *commercev1.User {
+	return &commercev1.User{
+		Id:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Role:      user.Role,
+		Age:       int32(user.Age),
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func toProtoOrder(order *entity.Order) *commercev1.Order {
+	items := make([]*commercev1.OrderItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, &commercev1.OrderItem{
+			Id:        item.ID,
+			ProductId: item.ProductID,
+			Name:      item.Name,
+			Quantity:  int32(item.Quantity),
+			Price:     item.Price,
+		})
+	}
+
+	return &commercev1.Order{
+		Id:        order.ID,
+		UserId:    order.UserID,
+		Status:    string(order.Status),
+		Total:     order.Total,
+		Items:     items,
+		CreatedAt: timestamppb.New(order.CreatedAt),
+		UpdatedAt: timestamppb.New(order.UpdatedAt),
+	}
+}
+
+func toProtoPage(page *entity.OrderPage) *commercev1.OrderPage {
+	orders := make([]*commercev1.Order, 0, len(page.Orders))
+	for i := range page.Orders {
+		orders = append(orders, toProtoOrder(&page.Orders[i]))
+	}
+	return &commercev1.OrderPage{Orders: orders, NextCursor: page.NextCursor}
+}
+
+func toProtoStatusChange(change entity.OrderStatusChange) *commercev1.OrderStatusChange {
+	var changedBy int64
+	if change.ChangedBy != nil {
+		changedBy = *change.ChangedBy
+	}
+	return &commercev1.OrderStatusChange{
+		Id:         change.ID,
+		OrderId:    change.OrderID,
+		FromStatus: string(change.FromStatus),
+		ToStatus:   string(change.ToStatus),
+		ChangedBy:  changedBy,
+		ChangedAt:  timestamppb.New(change.ChangedAt),
+	}
+}
+
+func fromItemInputs(inputs []*commercev1.OrderItemInput) []entity.OrderItem {
+	items := make([]entity.OrderItem, 0, len(inputs))
+	for _, input := range inputs {
+		items = append(items, entity.OrderItem{
+			ID:        input.GetId(),
+			ProductID: input.GetProductId(),
+			Quantity:  int(input.GetQuantity()),
+		})
+	}
+	return items
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
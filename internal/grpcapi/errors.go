@@ -0,0 +1,35 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus переводит доменные ошибки в коды gRPC по той же таблице, что и REST ручки.
+// Неизвестные ошибки наружу не отдаются, только в лог
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if kind, _, ok := apperr.Classify(err); ok {
+		return status.Error(kind.GRPCCode(), err.Error())
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		logging.FromContext(ctx).ErrorContext(ctx, "unexpected error", slog.Any("error", err))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
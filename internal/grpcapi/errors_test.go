@@ -0,0 +1,58 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/cache"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"unauthenticated", policy.ErrUnauthenticated, codes.Unauthenticated},
+		{"forbidden", policy.ErrForbidden, codes.PermissionDenied},
+		{"user not found", repositories.ErrUserNotFound, codes.NotFound},
+		{"wrapped order not found", fmt.Errorf("get: %w", repositories.ErrOrderNotFound), codes.NotFound},
+		{"email exists", repositories.ErrEmailExists, codes.AlreadyExists},
+		{"validation", entity.ErrInvalidEmail, codes.InvalidArgument},
+		{"short password", services.ErrPasswordTooShort, codes.InvalidArgument},
+		{"invalid cursor", entity.ErrInvalidCursor, codes.InvalidArgument},
+		{"not editable", services.ErrOrderNotEditable, codes.FailedPrecondition},
+		{"insufficient stock", repositories.ErrInsufficientStock, codes.FailedPrecondition},
+		{"status conflict", repositories.ErrOrderStatusConflict, codes.Aborted},
+		{"sku exists", repositories.ErrSKUExists, codes.AlreadyExists},
+		{"cart empty", services.ErrCartEmpty, codes.FailedPrecondition},
+		{"invalid credentials", services.ErrInvalidCredentials, codes.Unauthenticated},
+		{"invalid transition", &services.InvalidTransitionError{From: entity.OrderStatusShipped, To: entity.OrderStatusPending}, codes.FailedPrecondition},
+		{"redis down", cache.ErrUnavailable, codes.Unavailable},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"unknown", errors.New("db is down"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := toStatus(context.Background(), tt.err)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+
+	t.Run("internal error is hidden", func(t *testing.T) {
+		err := toStatus(context.Background(), errors.New("password=secret"))
+		assert.Equal(t, "internal error", status.Convert(err).Message())
+	})
+
+	assert.NoError(t, toStatus(context.Background(), nil))
+}
@@ -0,0 +1,78 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata тот же X-Request-ID, что и в REST, metadata всегда в нижнем регистре
+const requestIDMetadata = "x-request-id"
+
+const maxRequestIDLength = 128
+
+// LoggingInterceptor выдаёт вызову request id, кладёт логгер в контекст и пишет access log.
+// Стоит первым в цепочке, чтобы в лог попадали и отказы авторизации
+func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDMetadata); len(values) > 0 && len(values[0]) <= maxRequestIDLength {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+
+		requestLogger := logger.With(slog.String("route", info.FullMethod))
+		ctx = logging.WithRequestID(ctx, id)
+		ctx = logging.WithLogger(ctx, requestLogger)
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		switch code {
+		case codes.OK:
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+
+		requestLogger.LogAttrs(ctx, level, "request",
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return resp, err
+	}
+}
+
+// RecoveryInterceptor превращает панику в codes.Internal и пишет её в лог вызова.
+// Стоит после LoggingInterceptor, чтобы логгер уже был в контексте, а access log увидел код
+func RecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logging.FromContext(ctx).ErrorContext(ctx, "panic recovered",
+					slog.Any("panic", recovered),
+					slog.String("stack", string(debug.Stack())),
+				)
+				resp, err = nil, status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
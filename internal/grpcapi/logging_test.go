@@ -0,0 +1,43 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logged, recovery := LoggingInterceptor(logger), RecoveryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Panic"}
+
+	// Цепочка как в NewServer: access log снаружи, recovery внутри
+	resp, err := logged(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return recovery(ctx, req, info, func(context.Context, any) (any, error) {
+			panic("boom")
+		})
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal([]byte(raw), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, "panic recovered", lines[0]["msg"])
+	assert.Equal(t, "/svc/Panic", lines[0]["route"])
+	assert.Equal(t, codes.Internal.String(), lines[1]["code"])
+}
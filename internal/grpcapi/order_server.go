@@ -0,0 +1,141 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/commercev1"
+	"github.com/Belixk/CommerceTwo/internal/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type orderServer struct {
+	commercev1.UnimplementedOrderServiceServer
+	service *services.OrderService
+}
+
+func (s *orderServer) CreateOrder(ctx context.Context, req *commercev1.CreateOrderRequest) (*commercev1.Order, error) {
+	order := &entity.Order{
+		UserID: req.GetUserId(),
+		Items:  fromItemInputs(req.GetItems()),
+	}
+	if err := validateItems(order.Items); err != nil {
+		return nil, err
+	}
+
+	created, err := s.service.CreateOrder(ctx, order)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoOrder(created), nil
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *commercev1.GetOrderRequest) (*commercev1.Order, error) {
+	order, err := s.service.GetOrderByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoOrder(order), nil
+}
+
+func (s *orderServer) ListUserOrders(ctx context.Context, req *commercev1.ListUserOrdersRequest) (*commercev1.OrderPage, error) {
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+
+	page, err := s.service.GetOrdersByUserID(ctx, req.GetUserId(), req.GetCursor(), int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoPage(page), nil
+}
+
+func (s *orderServer) ListOrders(ctx context.Context, req *commercev1.ListOrdersRequest) (*commercev1.OrderPage, error) {
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+
+	filter := entity.OrderFilter{
+		UserID:      req.UserId,
+		MinTotal:    req.MinTotal,
+		MaxTotal:    req.MaxTotal,
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+		ItemName:    req.GetItemName(),
+		SortBy:      req.GetSortBy(),
+		Desc:        req.GetDesc(),
+		Limit:       int(req.GetLimit()),
+	}
+	if cursor := req.GetCursor(); cursor != "" {
+		after, err := entity.DecodeOrderCursor(cursor)
+		if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+		filter.After = after
+	}
+
+	page, err := s.service.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoPage(page), nil
+}
+
+func (s *orderServer) UpdateOrder(ctx context.Context, req *commercev1.UpdateOrderRequest) (*emptypb.Empty, error) {
+	order := &entity.Order{
+		ID:    req.GetId(),
+		Items: fromItemInputs(req.GetItems()),
+	}
+	if err := validateItems(order.Items); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.UpdateOrder(ctx, order); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *orderServer) DeleteOrder(ctx context.Context, req *commercev1.DeleteOrderRequest) (*emptypb.Empty, error) {
+	if err := s.service.DeleteOrder(ctx, req.GetId()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *orderServer) TransitionOrder(ctx context.Context, req *commercev1.TransitionOrderRequest) (*commercev1.Order, error) {
+	order, err := s.service.TransitionOrder(ctx, req.GetId(), entity.OrderStatus(req.GetStatus()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoOrder(order), nil
+}
+
+func (s *orderServer) GetStatusHistory(ctx context.Context, req *commercev1.GetStatusHistoryRequest) (*commercev1.GetStatusHistoryResponse, error) {
+	history, err := s.service.GetStatusHistory(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &commercev1.GetStatusHistoryResponse{Changes: make([]*commercev1.OrderStatusChange, 0, len(history))}
+	for _, change := range history {
+		resp.Changes = append(resp.Changes, toProtoStatusChange(change))
+	}
+	return resp, nil
+}
+
+// validateItems те же правила, что binding теги entity.Order в REST
+func validateItems(items []entity.OrderItem) error {
+	if len(items) == 0 {
+		return status.Error(codes.InvalidArgument, services.ErrOrderNoItems.Error())
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return status.Error(codes.InvalidArgument, "item quantity must be positive")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Package grpcapi gRPC транспорт поверх тех же сервисов, что и REST ручки
+package grpcapi
+
+import (
+	"log/slog"
+
+	"github.com/Belixk/CommerceTwo/internal/pb/commercev1"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/Belixk/CommerceTwo/internal/tracing"
+
+	"google.golang.org/grpc"
+)
+
+// NewServer собирает gRPC сервер с UserService и OrderService. Без токена доступна только регистрация
+func NewServer(logger *slog.Logger, parser AccessTokenParser, users *services.UserService, orders *services.OrderService) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			tracing.UnaryServerInterceptor(),
+			LoggingInterceptor(logger),
+			RecoveryInterceptor(),
+			AuthInterceptor(parser, commercev1.UserService_CreateUser_FullMethodName),
+		),
+	)
+
+	commercev1.RegisterUserServiceServer(server, &userServer{service: users})
+	commercev1.RegisterOrderServiceServer(server, &orderServer{service: orders})
+
+	return server
+}
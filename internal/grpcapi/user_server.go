@@ -0,0 +1,94 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pb/commercev1"
+	"github.com/Belixk/CommerceTwo/internal/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type userServer struct {
+	commercev1.UnimplementedUserServiceServer
+	service *services.UserService
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *commercev1.CreateUserRequest) (*commercev1.User, error) {
+	user := &entity.User{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Email:     req.GetEmail(),
+		Age:       int(req.GetAge()),
+	}
+
+	created, err := s.service.CreateUser(ctx, user, req.GetPassword())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoUser(created), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *commercev1.GetUserRequest) (*commercev1.User, error) {
+	user, err := s.service.GetUserById(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *commercev1.ListUsersRequest) (*commercev1.ListUsersResponse, error) {
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = 50
+	}
+	if limit < 0 || limit > 500 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+	if req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid offset")
+	}
+
+	users, err := s.service.ListUsers(ctx, limit, int(req.GetOffset()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &commercev1.ListUsersResponse{Users: make([]*commercev1.User, 0, len(users))}
+	for i := range users {
+		resp.Users = append(resp.Users, toProtoUser(&users[i]))
+	}
+	return resp, nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *commercev1.UpdateUserRequest) (*emptypb.Empty, error) {
+	user := &entity.User{
+		ID:        req.GetId(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Email:     req.GetEmail(),
+		Age:       int(req.GetAge()),
+	}
+
+	if err := s.service.UpdateUser(ctx, user); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *userServer) SetRole(ctx context.Context, req *commercev1.SetRoleRequest) (*emptypb.Empty, error) {
+	if err := s.service.SetRole(ctx, req.GetId(), req.GetRole()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *commercev1.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.service.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
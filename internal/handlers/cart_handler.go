@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CartIDHeader id анонимной корзины, клиент получает его в ответе и присылает обратно
+const CartIDHeader = "X-Cart-ID"
+
+type CartHandler struct {
+	service *services.CartService
+}
+
+func NewCartHandler(service *services.CartService) *CartHandler {
+	return &CartHandler{service: service}
+}
+
+type cartItemInput struct {
+	ProductID int64 `json:"product_id" binding:"required,gt=0"`
+	Quantity  int   `json:"quantity" binding:"required,gt=0"`
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	cart, err := h.service.GetCart(c.Request.Context(), c.GetHeader(CartIDHeader))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	h.respond(c, c.GetHeader(CartIDHeader), cart)
+}
+
+func (h *CartHandler) AddItem(c *gin.Context) {
+	var input cartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	// Первый товар анонима заводит новую корзину
+	cartID := c.GetHeader(CartIDHeader)
+	if _, ok := auth.FromContext(c.Request.Context()); !ok && cartID == "" {
+		id, err := services.NewCartID()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		cartID = id
+	}
+
+	cart, err := h.service.AddItem(c.Request.Context(), cartID, input.ProductID, input.Quantity)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	h.respond(c, cartID, cart)
+}
+
+func (h *CartHandler) UpdateItem(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("product_id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	var input struct {
+		Quantity *int `json:"quantity" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	cartID := c.GetHeader(CartIDHeader)
+	cart, err := h.service.UpdateItem(c.Request.Context(), cartID, productID, *input.Quantity)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	h.respond(c, cartID, cart)
+}
+
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("product_id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	cartID := c.GetHeader(CartIDHeader)
+	cart, err := h.service.RemoveItem(c.Request.Context(), cartID, productID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	h.respond(c, cartID, cart)
+}
+
+func (h *CartHandler) Checkout(c *gin.Context) {
+	order, err := h.service.Checkout(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// respond отдаёт id корзины только анониму, у пользователя корзина определяется по токену
+func (h *CartHandler) respond(c *gin.Context, cartID string, cart *entity.Cart) {
+	if _, ok := auth.FromContext(c.Request.Context()); !ok {
+		cart.ID = cartID
+		c.Header(CartIDHeader, cartID)
+	}
+	c.JSON(http.StatusOK, cart)
+}
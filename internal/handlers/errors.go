@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem тело ошибки по RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// writeProblem отвечает application/problem+json и прерывает цепочку обработчиков
+func writeProblem(c *gin.Context, status int, code, detail string) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+	if c.Request != nil {
+		problem.Instance = c.Request.URL.Path
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
+
+// writeBadRequest для ошибок разбора запроса: битый JSON, неверные параметры пути и query
+func writeBadRequest(c *gin.Context, detail string) {
+	writeProblem(c, http.StatusBadRequest, apperr.CodeInvalidRequest, detail)
+}
+
+// writeError переводит ошибку сервиса в HTTP ответ. Неизвестные ошибки уходят в лог,
+// клиент видит только internal_error без подробностей
+func writeError(c *gin.Context, err error) {
+	if kind, code, ok := apperr.Classify(err); ok {
+		writeProblem(c, kind.HTTPStatus(), code, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	if c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	logging.FromContext(ctx).ErrorContext(ctx, "unexpected error", slog.Any("error", err))
+	writeProblem(c, http.StatusInternalServerError, apperr.CodeInternal, "internal server error")
+}
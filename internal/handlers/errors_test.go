@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(err error) (*httptest.ResponseRecorder, Problem) {
+		r := gin.New()
+		r.GET("/orders/:id", func(c *gin.Context) { writeError(c, err) })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/orders/7", nil)
+		r.ServeHTTP(w, req)
+
+		var problem Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return w, problem
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"unauthenticated", policy.ErrUnauthenticated, http.StatusUnauthorized, apperr.CodeUnauthenticated},
+		{"forbidden", policy.ErrForbidden, http.StatusForbidden, apperr.CodeForbidden},
+		{"email exists", repositories.ErrEmailExists, http.StatusConflict, apperr.CodeEmailExists},
+		{"validation", entity.ErrInvalidEmail, http.StatusBadRequest, apperr.CodeValidationFailed},
+		{"wrapped not found", fmt.Errorf("get: %w", repositories.ErrOrderNotFound), http.StatusNotFound, apperr.CodeOrderNotFound},
+		{"insufficient stock", fmt.Errorf("%w: product 3", repositories.ErrInsufficientStock), http.StatusConflict, apperr.CodeInsufficientStock},
+		{"product unavailable", services.ErrProductUnavailable, http.StatusUnprocessableEntity, apperr.CodeProductUnavailable},
+		{"invalid transition", &services.InvalidTransitionError{From: entity.OrderStatusShipped, To: entity.OrderStatusPending}, http.StatusConflict, apperr.CodeInvalidTransition},
+		{"database outage", errors.New("connection refused"), http.StatusInternalServerError, apperr.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, problem := serve(tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, http.StatusText(tt.status), problem.Title)
+			assert.Equal(t, "/orders/7", problem.Instance)
+		})
+	}
+
+	t.Run("unknown error is not leaked", func(t *testing.T) {
+		w, problem := serve(errors.New("pq: password authentication failed"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "password")
+		assert.Equal(t, "internal server error", problem.Detail)
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize тело читается целиком ради отпечатка, поэтому его размер ограничен
+	maxIdempotentBodySize = 1 << 20
+)
+
+// Сколько держится блокировка без продления и как часто дубль проверяет, не закончился ли первый запрос.
+// Пока обработчик работает, блокировка продлевается каждую треть TTL
+var (
+	idempotencyLockTTL      = 30 * time.Second
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// IdempotencyStore блокировка принадлежит owner: продлить, завершить или снять её может только он
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (*repositories.IdempotencyRecord, bool, error)
+	Extend(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) error
+	Complete(ctx context.Context, key, owner string, record *repositories.IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key, fingerprint, owner string) error
+}
+
+// IdempotencyMiddleware повторяет ответ на запрос с уже виденным Idempotency-Key вместо повторного выполнения.
+// Ключ привязан к пользователю и маршруту, поэтому ставится после AuthMiddleware.
+// Параллельный дубль ждёт, пока первый запрос закончится, и получает его ответ
+func IdempotencyMiddleware(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeBadRequest(c, "idempotency key is too long")
+			return
+		}
+
+		identity, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			writeProblem(c, http.StatusUnauthorized, apperr.CodeUnauthenticated, "missing bearer token")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeProblem(c, http.StatusRequestEntityTooLarge, apperr.CodeRequestTooLarge,
+					fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+				return
+			}
+			writeBadRequest(c, err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+		storeKey := fmt.Sprintf("idempotency:%d:%s %s:%s", identity.UserID, c.Request.Method, c.FullPath(), key)
+
+		owner := rand.Text()
+		proceed, err := acquireIdempotencyKey(c, store, storeKey, fingerprint, owner)
+		if err != nil {
+			ctx := c.Request.Context()
+			if ctx.Err() != nil {
+				c.Abort()
+				return
+			}
+			// Без хранилища ключей запрос выполняется как обычный, без защиты от повтора
+			logging.FromContext(ctx).WarnContext(ctx, "idempotency store is unavailable, processing request without it",
+				slog.Any("error", err))
+			c.Next()
+			return
+		}
+		if !proceed {
+			return
+		}
+
+		// Ответ сохраняем, даже если клиент уже отвалился, ради этого он и будет повторять запрос
+		ctx := context.WithoutCancel(c.Request.Context())
+		stopExtending := extendIdempotencyLock(ctx, store, storeKey, fingerprint, owner)
+
+		completed := false
+		// Снимаем блокировку и при панике обработчика, иначе дубли ждали бы её истечения
+		defer func() {
+			stopExtending()
+			if !completed {
+				_ = store.Release(ctx, storeKey, fingerprint, owner)
+			}
+		}()
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// Запоминаем только успех: после ошибки запрос можно честно повторить с тем же ключом
+		status := writer.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+
+		record := &repositories.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		completed = store.Complete(ctx, storeKey, owner, record, ttl) == nil
+	}
+}
+
+// extendIdempotencyLock продлевает блокировку в фоне, пока не вызвана возвращённая функция
+func extendIdempotencyLock(ctx context.Context, store IdempotencyStore, key, fingerprint, owner string) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(idempotencyLockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Блокировку перехватили, продлевать больше нечего
+				if err := store.Extend(ctx, key, fingerprint, owner, idempotencyLockTTL); errors.Is(err, repositories.ErrIdempotencyLockLost) {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// acquireIdempotencyKey занимает ключ или отвечает сам: повтором сохранённого ответа,
+// 422 при другом теле запроса или 409, если первый запрос так и не закончился.
+// Ошибка означает, что хранилище недоступно и ответ ещё не записан
+func acquireIdempotencyKey(c *gin.Context, store IdempotencyStore, key, fingerprint, owner string) (bool, error) {
+	ctx := c.Request.Context()
+	deadline := time.Now().Add(idempotencyLockTTL)
+
+	for {
+		record, acquired, err := store.Begin(ctx, key, fingerprint, owner, idempotencyLockTTL)
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			return true, nil
+		}
+
+		if record != nil {
+			if record.Fingerprint != fingerprint {
+				writeProblem(c, http.StatusUnprocessableEntity, apperr.CodeIdempotencyMismatch, "idempotency key was used with a different request body")
+				return false, nil
+			}
+			if record.Completed {
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Data(record.Status, record.ContentType, record.Body)
+				c.Abort()
+				return false, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			writeProblem(c, http.StatusConflict, apperr.CodeIdempotencyInFlight, "request with this idempotency key is still in progress")
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			c.Abort()
+			return false, nil
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// capturingWriter пишет ответ клиенту и параллельно копит его для сохранения
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
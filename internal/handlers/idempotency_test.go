@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore повторяет семантику SET NX и скриптов с проверкой владельца из Redis
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]repositories.IdempotencyRecord
+	extends int
+}
+
+func (s *memoryIdempotencyStore) Begin(_ context.Context, key, fingerprint, owner string, _ time.Duration) (*repositories.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		return &record, false, nil
+	}
+	s.records[key] = repositories.IdempotencyRecord{Fingerprint: fingerprint, Owner: owner}
+	return nil, true, nil
+}
+
+// owns вызывается под мьютексом
+func (s *memoryIdempotencyStore) owns(key, owner string) bool {
+	record, ok := s.records[key]
+	return ok && !record.Completed && record.Owner == owner
+}
+
+func (s *memoryIdempotencyStore) Extend(_ context.Context, key, _, owner string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.owns(key, owner) {
+		return repositories.ErrIdempotencyLockLost
+	}
+	s.extends++
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key, owner string, record *repositories.IdempotencyRecord, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.owns(key, owner) {
+		return repositories.ErrIdempotencyLockLost
+	}
+	record.Completed = true
+	s.records[key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key, _, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.owns(key, owner) {
+		return repositories.ErrIdempotencyLockLost
+	}
+	delete(s.records, key)
+	return nil
+}
+
+// downIdempotencyStore хранилище без Redis
+type downIdempotencyStore struct{ memoryIdempotencyStore }
+
+func (*downIdempotencyStore) Begin(context.Context, string, string, string, time.Duration) (*repositories.IdempotencyRecord, bool, error) {
+	return nil, false, errors.New("cache unavailable")
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(userID int64, calls *atomic.Int64, status int, delay time.Duration) (*gin.Engine, *memoryIdempotencyStore) {
+		store := &memoryIdempotencyStore{records: map[string]repositories.IdempotencyRecord{}}
+		r := gin.New()
+		r.POST("/orders",
+			func(c *gin.Context) {
+				ctx := auth.WithIdentity(c.Request.Context(), auth.Identity{UserID: userID, Role: auth.RoleCustomer})
+				c.Request = c.Request.WithContext(ctx)
+			},
+			IdempotencyMiddleware(store, time.Hour),
+			func(c *gin.Context) {
+				n := calls.Add(1)
+				time.Sleep(delay)
+				c.JSON(status, gin.H{"id": n})
+			},
+		)
+		return r, store
+	}
+
+	send := func(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("replays stored response", func(t *testing.T) {
+		var calls atomic.Int64
+		r, _ := newRouter(1, &calls, http.StatusCreated, 0)
+
+		first := send(r, "k1", `{"items":[]}`)
+		second := send(r, "k1", `{"items":[]}`)
+
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get(IdempotencyReplayedHeader))
+		assert.Equal(t, int64(1), calls.Load())
+	})
+
+	t.Run("different body is rejected", func(t *testing.T) {
+		var calls atomic.Int64
+		r, _ := newRouter(1, &calls, http.StatusCreated, 0)
+
+		send(r, "k1", `{"items":[1]}`)
+		w := send(r, "k1", `{"items":[2]}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, int64(1), calls.Load())
+	})
+
+	t.Run("failed request can be retried", func(t *testing.T) {
+		var calls atomic.Int64
+		r, store := newRouter(1, &calls, http.StatusConflict, 0)
+
+		send(r, "k1", `{}`)
+		send(r, "k1", `{}`)
+
+		assert.Equal(t, int64(2), calls.Load())
+		assert.Empty(t, store.records)
+	})
+
+	t.Run("concurrent duplicates run once", func(t *testing.T) {
+		var calls atomic.Int64
+		r, _ := newRouter(1, &calls, http.StatusCreated, 100*time.Millisecond)
+
+		var wg sync.WaitGroup
+		responses := make([]*httptest.ResponseRecorder, 5)
+		for i := range responses {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				responses[i] = send(r, "k1", `{}`)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(1), calls.Load())
+		for _, w := range responses {
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.JSONEq(t, `{"id": 1}`, w.Body.String())
+		}
+	})
+
+	t.Run("keys are scoped to the caller", func(t *testing.T) {
+		var calls atomic.Int64
+		store := &memoryIdempotencyStore{records: map[string]repositories.IdempotencyRecord{}}
+		r := gin.New()
+		r.POST("/orders",
+			func(c *gin.Context) {
+				userID := int64(1)
+				if c.GetHeader("X-User") == "2" {
+					userID = 2
+				}
+				c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), auth.Identity{UserID: userID}))
+			},
+			IdempotencyMiddleware(store, time.Hour),
+			func(c *gin.Context) {
+				calls.Add(1)
+				c.Status(http.StatusCreated)
+			},
+		)
+
+		for _, user := range []string{"1", "2"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+			req.Header.Set(IdempotencyKeyHeader, "shared")
+			req.Header.Set("X-User", user)
+			r.ServeHTTP(w, req)
+		}
+
+		assert.Equal(t, int64(2), calls.Load())
+	})
+
+	t.Run("panic releases the key", func(t *testing.T) {
+		store := &memoryIdempotencyStore{records: map[string]repositories.IdempotencyRecord{}}
+		r := gin.New()
+		r.Use(gin.CustomRecovery(func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+		r.POST("/orders",
+			func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), auth.Identity{UserID: 1}))
+			},
+			IdempotencyMiddleware(store, time.Hour),
+			func(*gin.Context) { panic("boom") },
+		)
+
+		w := send(r, "k1", `{}`)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, store.records)
+	})
+
+	t.Run("slow handler keeps the lock", func(t *testing.T) {
+		defer func(ttl time.Duration) { idempotencyLockTTL = ttl }(idempotencyLockTTL)
+		idempotencyLockTTL = 30 * time.Millisecond
+
+		var calls atomic.Int64
+		r, store := newRouter(1, &calls, http.StatusCreated, 100*time.Millisecond)
+
+		w := send(r, "k1", `{}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.GreaterOrEqual(t, store.extends, 2)
+		assert.Len(t, store.records, 1)
+		for _, record := range store.records {
+			assert.True(t, record.Completed)
+		}
+	})
+
+	t.Run("store down processes request without protection", func(t *testing.T) {
+		var calls atomic.Int64
+		r := gin.New()
+		r.POST("/orders",
+			func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), auth.Identity{UserID: 1}))
+			},
+			IdempotencyMiddleware(&downIdempotencyStore{}, time.Hour),
+			func(c *gin.Context) {
+				calls.Add(1)
+				c.Status(http.StatusCreated)
+			},
+		)
+
+		w := send(r, "k1", `{}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, int64(1), calls.Load())
+	})
+	t.Run("oversized body is rejected before fingerprinting", func(t *testing.T) {
+		var calls atomic.Int64
+		r, store := newRouter(1, &calls, http.StatusCreated, 0)
+
+		w := send(r, "k1", strings.Repeat("a", maxIdempotentBodySize+1))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), apperr.CodeRequestTooLarge)
+		assert.Zero(t, calls.Load())
+		assert.Empty(t, store.records)
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/pkg/token"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := token.NewJWTManager("test-secret", time.Minute)
+
+	newRouter := func(buf *bytes.Buffer) *gin.Engine {
+		logger, err := logging.New(buf, logging.FormatJSON, "info")
+		require.NoError(t, err)
+
+		r := gin.New()
+		r.Use(RequestLogger(logger), Recovery())
+		r.GET("/orders/:id", AuthMiddleware(manager), func(c *gin.Context) {
+			ctx := c.Request.Context()
+			logging.FromContext(ctx).InfoContext(ctx, "loading order")
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+		})
+		r.GET("/panic", func(c *gin.Context) { panic("boom") })
+		return r
+	}
+
+	lines := func(buf *bytes.Buffer) []map[string]any {
+		var out []map[string]any
+		for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal([]byte(raw), &line))
+			out = append(out, line)
+		}
+		return out
+	}
+
+	t.Run("every line carries request id, user and route", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf)
+
+		access, err := manager.NewAccessToken(42, auth.RoleCustomer)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/orders/7", nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		req.Header.Set(RequestIDHeader, "client-id-1")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "client-id-1", w.Header().Get(RequestIDHeader))
+
+		logged := lines(&buf)
+		require.Len(t, logged, 2)
+		for _, line := range logged {
+			assert.Equal(t, "client-id-1", line["request_id"])
+			assert.Equal(t, float64(42), line["user_id"])
+			assert.Equal(t, "/orders/:id", line["route"])
+		}
+
+		accessLine := logged[1]
+		assert.Equal(t, "request", accessLine["msg"])
+		assert.Equal(t, float64(http.StatusOK), accessLine["status"])
+		assert.Contains(t, accessLine, "latency")
+	})
+
+	t.Run("generates id when header is missing or invalid", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/orders/7", nil)
+		req.Header.Set(RequestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		assert.Len(t, id, 32)
+
+		logged := lines(&buf)
+		require.Len(t, logged, 1)
+		assert.Equal(t, id, logged[0]["request_id"])
+		assert.Equal(t, "WARN", logged[0]["level"])
+		assert.Equal(t, float64(http.StatusUnauthorized), logged[0]["status"])
+	})
+
+	t.Run("panic becomes a logged 500", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRouter(&buf)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+		logged := lines(&buf)
+		require.Len(t, logged, 2)
+		assert.Equal(t, "panic recovered", logged[0]["msg"])
+		assert.Equal(t, "ERROR", logged[1]["level"])
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Belixk/CommerceTwo/internal/apperr"
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/pkg/token"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenParser interface {
+	ParseAccessToken(tokenStr string) (*token.Claims, error)
+}
+
+// AuthMiddleware достаёт пользователя из bearer токена и кладёт его в контекст запроса
+func AuthMiddleware(parser AccessTokenParser) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenStr, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenStr == "" {
+			writeProblem(c, http.StatusUnauthorized, apperr.CodeUnauthenticated, "missing bearer token")
+			return
+		}
+
+		claims, err := parser.ParseAccessToken(tokenStr)
+		if err != nil {
+			writeProblem(c, http.StatusUnauthorized, apperr.CodeUnauthenticated, err.Error())
+			return
+		}
+
+		identity := auth.Identity{UserID: claims.UserID, Role: claims.Role}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware пускает и без токена, но если токен передан, он должен быть валидным
+func OptionalAuthMiddleware(parser AccessTokenParser) gin.HandlerFunc {
+	required := AuthMiddleware(parser)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		required(c)
+	}
+}
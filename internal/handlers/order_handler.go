@@ -1,32 +1,61 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/apperr"
 	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/pkg/mergepatch"
 	"github.com/Belixk/CommerceTwo/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type OrderHandler struct {
 	service *services.OrderService
 }
 
+// orderInput то, что клиент передаёт при создании и изменении заказа.
+// Название и цену позиций сервер берёт из каталога, статус меняется отдельной ручкой
+type orderInput struct {
+	UserID int64            `json:"user_id"`
+	Items  []orderItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+type orderItemInput struct {
+	ID        int64 `json:"id"`
+	ProductID int64 `json:"product_id"`
+	Quantity  int   `json:"quantity" binding:"required,gt=0"`
+}
+
+func (in orderInput) order(id int64) *entity.Order {
+	order := &entity.Order{ID: id, UserID: in.UserID, Items: make([]entity.OrderItem, 0, len(in.Items))}
+	for _, item := range in.Items {
+		order.Items = append(order.Items, entity.OrderItem{ID: item.ID, OrderID: id, ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return order
+}
+
 func NewOrderHandler(service *services.OrderService) *OrderHandler {
 	return &OrderHandler{service: service}
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-	var input entity.Order
+	var input orderInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeBadRequest(c, err.Error())
 		return
 	}
 
-	order, err := h.service.CreateOrder(c.Request.Context(), &input)
+	order, err := h.service.CreateOrder(c.Request.Context(), input.order(0))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -36,13 +65,13 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		writeBadRequest(c, "invalid order id")
 		return
 	}
 
 	order, err := h.service.GetOrderByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		writeError(c, err)
 		return
 	}
 
@@ -52,44 +81,273 @@ func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 func (h *OrderHandler) GetOrdersByUserID(c *gin.Context) {
 	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		writeBadRequest(c, "invalid user id")
 		return
 	}
 
-	order, err := h.service.GetOrderbyUserID(c.Request.Context(), userID)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		writeBadRequest(c, "invalid limit")
+		return
+	}
+
+	page, err := h.service.GetOrdersByUserID(c.Request.Context(), userID, c.Query("cursor"), limit)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "orders not found for this user"})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	page, err := h.service.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *OrderHandler) UpdateOrder(c *gin.Context) {
-	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid order id")
+		return
+	}
 
-	var input entity.Order
+	var input orderInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeBadRequest(c, err.Error())
 		return
 	}
-	input.ID = id
 
-	if err := h.service.UpdateOrder(c.Request.Context(), &input); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.UpdateOrder(c.Request.Context(), input.order(id)); err != nil {
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "order updated"})
 }
 
+// PatchOrder частично обновляет заказ по JSON Merge Patch (RFC 7386), массив items заменяется целиком
+func (h *OrderHandler) PatchOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid order id")
+		return
+	}
+
+	if ct := c.ContentType(); ct != "application/merge-patch+json" && ct != "application/json" {
+		writeProblem(c, http.StatusUnsupportedMediaType, apperr.CodeUnsupportedMediaType, "expected application/merge-patch+json")
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if field, err := readOnlyPatchField(patch); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	} else if field != "" {
+		writeProblem(c, http.StatusUnprocessableEntity, apperr.CodeReadOnlyField, readOnlyFieldDetail(field))
+		return
+	}
+
+	current, err := h.service.GetOrderByID(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	merged, err := mergepatch.Apply(original, patch)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	var input orderInput
+	if err := json.Unmarshal(merged, &input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	order := input.order(id)
+	if err := h.service.UpdateOrder(c.Request.Context(), order); err != nil {
+		writeError(c, err)
+		return
+	}
+	order.CreatedAt = current.CreatedAt
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) TransitionOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid order id")
+		return
+	}
+
+	var input struct {
+		Status entity.OrderStatus `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	order, err := h.service.TransitionOrder(c.Request.Context(), id, input.Status)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid order id")
+		return
+	}
+
+	history, err := h.service.GetStatusHistory(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
 func (h *OrderHandler) DeleteOrder(c *gin.Context) {
-	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid order id")
+		return
+	}
 
 	if err := h.service.DeleteOrder(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "order deleted"})
 }
+
+// patchableOrderFields через PATCH меняется только состав заказа
+var patchableOrderFields = map[string]bool{"items": true}
+
+// readOnlyPatchField возвращает первое поле патча, которое нельзя менять.
+// Молча игнорировать их нельзя: клиент получил бы 200 и решил, что статус сменился
+func readOnlyPatchField(patch []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return "", mergepatch.ErrInvalidPatch
+	}
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		if !patchableOrderFields[field] {
+			return field, nil
+		}
+	}
+	return "", nil
+}
+
+func readOnlyFieldDetail(field string) string {
+	if field == "status" {
+		return "status cannot be patched, use POST /api/v1/orders/{id}/transitions"
+	}
+	return field + " cannot be patched, only items can"
+}
+
+// parseOrderFilter разбирает query параметры списка заказов, пустые параметры не фильтруют.
+// Ошибки оборачивают entity.ErrInvalidFilter или entity.ErrInvalidCursor
+func parseOrderFilter(c *gin.Context) (entity.OrderFilter, error) {
+	filter := entity.OrderFilter{
+		ItemName: c.Query("item"),
+		SortBy:   c.Query("sort"),
+		Desc:     c.DefaultQuery("order", "desc") == "desc",
+	}
+
+	var err error
+	if filter.UserID, err = queryInt64(c, "user_id"); err != nil {
+		return filter, err
+	}
+	if filter.MinTotal, err = queryInt64(c, "min_total"); err != nil {
+		return filter, err
+	}
+	if filter.MaxTotal, err = queryInt64(c, "max_total"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedFrom, err = queryTime(c, "created_from"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = queryTime(c, "created_to"); err != nil {
+		return filter, err
+	}
+
+	if order := c.Query("order"); order != "" && order != "asc" && order != "desc" {
+		return filter, fmt.Errorf("%w: invalid order %q", entity.ErrInvalidFilter, order)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, fmt.Errorf("%w: invalid limit", entity.ErrInvalidFilter)
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.After, err = entity.DecodeOrderCursor(cursor); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+func queryInt64(c *gin.Context, name string) (*int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s", entity.ErrInvalidFilter, name)
+	}
+	return &v, nil
+}
+
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s, expected RFC3339", entity.ErrInvalidFilter, name)
+	}
+	return &t, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -26,5 +27,53 @@ func TestOrderHandler_CreateOrder_ValidationError(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), "error")
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"code":"invalid_request"`)
+}
+
+func TestOrderHandler_PatchOrder_ReadOnlyFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &OrderHandler{service: nil}
+
+	r := gin.New()
+	r.PATCH("/orders/:id", h.PatchOrder)
+
+	patch := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch("/orders/7", `{"status":"paid"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"read_only_field"`)
+	assert.Contains(t, w.Body.String(), "/transitions")
+
+	w = patch("/orders/7", `{"items":[],"user_id":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "user_id cannot be patched")
+
+	w = patch("/orders/7", `[]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOrderHandler_RejectsInvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &OrderHandler{service: nil}
+
+	r := gin.New()
+	r.PUT("/orders/:id", h.UpdateOrder)
+	r.DELETE("/orders/:id", h.DeleteOrder)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req, _ := http.NewRequest(method, "/orders/abc", strings.NewReader(`{"items":[{"product_id":1,"quantity":1}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, method)
+		assert.Contains(t, w.Body.String(), "invalid order id", method)
+	}
 }
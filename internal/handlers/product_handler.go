@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ProductHandler struct {
+	service *services.ProductService
+}
+
+func NewProductHandler(service *services.ProductService) *ProductHandler {
+	return &ProductHandler{service: service}
+}
+
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var input entity.Product
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	product, err := h.service.CreateProduct(c.Request.Context(), &input)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, product)
+}
+
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	product, err := h.service.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// ListProducts публичная витрина, только товары в продаже
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	h.listProducts(c, false)
+}
+
+// ListAllProducts список для админки вместе со снятыми с продажи
+func (h *ProductHandler) ListAllProducts(c *gin.Context) {
+	h.listProducts(c, true)
+}
+
+func (h *ProductHandler) listProducts(c *gin.Context, includeInactive bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		writeBadRequest(c, "invalid limit")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		writeBadRequest(c, "invalid offset")
+		return
+	}
+
+	products, err := h.service.ListProducts(c.Request.Context(), includeInactive, limit, offset)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	var input entity.Product
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	input.ID = id
+
+	if err := h.service.UpdateProduct(c.Request.Context(), &input); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product updated"})
+}
+
+type adjustStockRequest struct {
+	Delta int `json:"delta" binding:"required"`
+}
+
+// AdjustStock меняет остаток на delta, а не выставляет его: параллельные заказы не теряют резерв
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	var input adjustStockRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	stock, err := h.service.AdjustStock(c.Request.Context(), id, input.Delta)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "stock": stock})
+}
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid product id")
+		return
+	}
+
+	if err := h.service.DeleteProduct(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product deleted"})
+}
@@ -24,18 +24,13 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		writeBadRequest(c, err.Error())
 		return
 	}
 
 	user, err := h.service.CreateUser(c.Request.Context(), &input.User, input.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":    err.Error(),
-			"messages": "could not create user",
-		})
+		writeError(c, err)
 		return
 	}
 
@@ -46,50 +41,88 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":    err.Error(),
-			"messages": "invalid id format",
-		})
+		writeBadRequest(c, "invalid id format")
 		return
 	}
 
 	user, err := h.service.GetUserById(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":    err.Error(),
-			"messages": "user not found",
-		})
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
 
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		writeBadRequest(c, "invalid limit")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		writeBadRequest(c, "invalid offset")
+		return
+	}
+
+	users, err := h.service.ListUsers(c.Request.Context(), limit, offset)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseInt(idStr, 10, 64)
 
 	var input entity.User
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeBadRequest(c, err.Error())
 		return
 	}
 	input.ID = id
 
 	if err := h.service.UpdateUser(c.Request.Context(), &input); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user updated"})
 }
 
+func (h *UserHandler) SetRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid user id")
+		return
+	}
+
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.service.SetRole(c.Request.Context(), id, input.Role); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
+
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseInt(idStr, 10, 64)
 
 	if err := h.service.DeleteUser(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
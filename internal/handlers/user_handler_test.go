@@ -23,6 +23,6 @@ func TestUserHandler(t *testing.T) {
 		h.GetUser(c)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "invalid is format")
+		assert.Contains(t, w.Body.String(), "invalid id format")
 	})
 }
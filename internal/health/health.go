@@ -0,0 +1,170 @@
+// Package health проверки готовности зависимостей для /readyz
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDegraded = "degraded" // необязательная зависимость недоступна, сервис работает без неё
+	StatusDraining = "draining"
+)
+
+var (
+	ErrDirtyMigration   = errors.New("migration is dirty")
+	ErrMigrationVersion = errors.New("unexpected migration version")
+)
+
+// Check проверяет одну зависимость, контекст уже ограничен таймаутом
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Optional  bool   `json:"optional,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready в degraded сервис остаётся в ротации: он отвечает, пусть и медленнее
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type Checker struct {
+	timeout  time.Duration
+	names    []string
+	checks   map[string]Check
+	optional map[string]bool
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout:  timeout,
+		checks:   make(map[string]Check),
+		optional: make(map[string]bool),
+	}
+}
+
+// Add регистрирует проверку. Вызывается при сборке приложения, до первого запроса
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+	delete(c.optional, name)
+}
+
+// AddOptional регистрирует проверку зависимости, без которой сервис работает.
+// Её отказ переводит отчёт в degraded, а не в fail
+func (c *Checker) AddOptional(name string, check Check) {
+	c.Add(name, check)
+	c.optional[name] = true
+}
+
+// Drain переводит сервис в not ready. После него балансировщик перестаёт слать трафик,
+// а уже принятые запросы дорабатывают до Shutdown
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check запускает все проверки параллельно, каждую под своим таймаутом
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining}
+	}
+
+	results := make([]Result, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, c.checks[name])
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.names))}
+	for i, name := range c.names {
+		result := results[i]
+		result.Optional = c.optional[name]
+		report.Checks[name] = result
+
+		switch {
+		case result.Status == StatusOK:
+		case result.Optional:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{Status: StatusOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err == nil {
+		// Проверка могла проигнорировать контекст и вернуться уже после таймаута
+		err = ctx.Err()
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func Postgres(db *sql.DB) Check {
+	return db.PingContext
+}
+
+func Redis(rdb redis.UniversalClient) Check {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// Migrations проверяет, что схема не грязная и не старее той, что применил этот экземпляр при старте.
+// Более новая версия штатна при rolling deploy: новые реплики уже накатили свои миграции,
+// а старые должны оставаться в ротации до замены
+func Migrations(db *sql.DB, expected uint) Check {
+	return func(ctx context.Context) error {
+		var (
+			version uint
+			dirty   bool
+		)
+		if err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w: version %d", ErrDirtyMigration, version)
+		}
+		if version < expected {
+			return fmt.Errorf("%w: got %d, want at least %d", ErrMigrationVersion, version, expected)
+		}
+		return nil
+	}
+}
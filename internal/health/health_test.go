@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker_Check(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all checks pass", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Add("postgres", func(context.Context) error { return nil })
+		checker.Add("redis", func(context.Context) error { return nil })
+
+		report := checker.Check(ctx)
+
+		assert.True(t, report.Ready())
+		assert.Equal(t, StatusOK, report.Checks["postgres"].Status)
+		assert.Equal(t, StatusOK, report.Checks["redis"].Status)
+	})
+
+	t.Run("one failing check makes service not ready", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Add("postgres", func(context.Context) error { return nil })
+		checker.Add("redis", func(context.Context) error { return errors.New("connection refused") })
+
+		report := checker.Check(ctx)
+
+		assert.False(t, report.Ready())
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["postgres"].Status)
+		assert.Equal(t, "connection refused", report.Checks["redis"].Error)
+	})
+
+	t.Run("optional failure degrades but stays ready", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Add("postgres", func(context.Context) error { return nil })
+		checker.AddOptional("redis", func(context.Context) error { return errors.New("connection refused") })
+
+		report := checker.Check(ctx)
+
+		assert.True(t, report.Ready())
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.True(t, report.Checks["redis"].Optional)
+		assert.Equal(t, StatusFail, report.Checks["redis"].Status)
+	})
+
+	t.Run("required failure wins over degraded", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.AddOptional("redis", func(context.Context) error { return errors.New("connection refused") })
+		checker.Add("postgres", func(context.Context) error { return errors.New("connection refused") })
+
+		assert.Equal(t, StatusFail, checker.Check(ctx).Status)
+	})
+
+	t.Run("slow check is cut by timeout", func(t *testing.T) {
+		checker := NewChecker(20 * time.Millisecond)
+		checker.Add("postgres", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := checker.Check(ctx)
+
+		assert.False(t, report.Ready())
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["postgres"].Error)
+	})
+
+	t.Run("draining skips checks", func(t *testing.T) {
+		called := false
+		checker := NewChecker(time.Second)
+		checker.Add("postgres", func(context.Context) error { called = true; return nil })
+		checker.Drain()
+
+		report := checker.Check(ctx)
+
+		assert.Equal(t, StatusDraining, report.Status)
+		assert.False(t, report.Ready())
+		assert.False(t, called)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := `SELECT version, dirty FROM schema_migrations`
+	check := Migrations(db, 9)
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(9, false))
+	assert.NoError(t, check(context.Background()))
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(8, false))
+	assert.ErrorIs(t, check(context.Background()), ErrMigrationVersion)
+
+	// Новая реплика уже накатила следующую миграцию, старая остаётся готовой
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(10, false))
+	assert.NoError(t, check(context.Background()))
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(9, true))
+	assert.ErrorIs(t, check(context.Background()), ErrDirtyMigration)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("json lines carry request fields from context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := New(&buf, FormatJSON, "info")
+		assert.NoError(t, err)
+
+		ctx := WithRequestID(context.Background(), "req-1")
+		ctx = auth.WithIdentity(ctx, auth.Identity{UserID: 42, Role: auth.RoleCustomer})
+		logger.With("route", "/api/v1/orders/:id").InfoContext(ctx, "order loaded")
+
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "order loaded", line["msg"])
+		assert.Equal(t, "req-1", line["request_id"])
+		assert.Equal(t, float64(42), line["user_id"])
+		assert.Equal(t, "/api/v1/orders/:id", line["route"])
+	})
+
+	t.Run("trace id from span context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := New(&buf, FormatJSON, "info")
+		assert.NoError(t, err)
+
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  spanID,
+		}))
+		logger.InfoContext(ctx, "traced")
+
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	})
+
+	t.Run("text format and level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := New(&buf, FormatText, "warn")
+		assert.NoError(t, err)
+
+		logger.Info("hidden")
+		logger.Warn("shown")
+
+		assert.NotContains(t, buf.String(), "hidden")
+		assert.Contains(t, buf.String(), "msg=shown")
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := New(&bytes.Buffer{}, "xml", "info")
+		assert.Error(t, err)
+
+		_, err = New(&bytes.Buffer{}, FormatJSON, "loud")
+		assert.Error(t, err)
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, FormatJSON, "info")
+
+	ctx := WithLogger(context.Background(), logger)
+	assert.Same(t, logger, FromContext(ctx))
+	assert.NotNil(t, FromContext(context.Background()))
+}
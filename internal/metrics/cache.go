@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// readResult отличает промах (redis.Nil) и закешированный "не найдено" от настоящей ошибки Redis.
+// Значение в формате, который эта версия не читает, тоже промах
+func readResult(err error) string {
+	switch {
+	case err == nil:
+		return CacheHit
+	case errors.Is(err, redis.Nil), errors.Is(err, codec.ErrUnknownFormat):
+		return CacheMiss
+	case errors.Is(err, repositories.ErrUserNotFound), errors.Is(err, repositories.ErrOrderNotFound):
+		return CacheNegativeHit
+	default:
+		return CacheError
+	}
+}
+
+func writeResult(err error) string {
+	if err != nil {
+		return CacheError
+	}
+	return CacheOK
+}
+
+func (m *Metrics) countCache(cache, op, result string) {
+	m.cacheOperations.WithLabelValues(cache, op, result).Inc()
+}
+
+type userCache struct {
+	next    services.UserCache
+	metrics *Metrics
+}
+
+// InstrumentUserCache считает попадания, промахи и ошибки кеша пользователей
+func InstrumentUserCache(next services.UserCache, m *Metrics) services.UserCache {
+	return &userCache{next: next, metrics: m}
+}
+
+func (c *userCache) Get(ctx context.Context, key string) (*entity.User, error) {
+	user, err := c.next.Get(ctx, key)
+	c.metrics.countCache("user", "get", readResult(err))
+	return user, err
+}
+
+func (c *userCache) Set(ctx context.Context, key string, user *entity.User, ttl time.Duration) error {
+	err := c.next.Set(ctx, key, user, ttl)
+	c.metrics.countCache("user", setOp(user == nil), writeResult(err))
+	return err
+}
+
+func (c *userCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	err := c.next.SetNotFound(ctx, key, ttl)
+	c.metrics.countCache("user", "set_not_found", writeResult(err))
+	return err
+}
+
+type orderCache struct {
+	next    services.Cache
+	metrics *Metrics
+}
+
+// InstrumentOrderCache то же для кеша заказов и страниц списков
+func InstrumentOrderCache(next services.Cache, m *Metrics) services.Cache {
+	return &orderCache{next: next, metrics: m}
+}
+
+func (c *orderCache) Get(ctx context.Context, key string) (*entity.Order, error) {
+	order, err := c.next.Get(ctx, key)
+	c.metrics.countCache("order", "get", readResult(err))
+	return order, err
+}
+
+func (c *orderCache) Set(ctx context.Context, key string, order *entity.Order, ttl time.Duration) error {
+	err := c.next.Set(ctx, key, order, ttl)
+	c.metrics.countCache("order", setOp(order == nil), writeResult(err))
+	return err
+}
+
+func (c *orderCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	err := c.next.SetNotFound(ctx, key, ttl)
+	c.metrics.countCache("order", "set_not_found", writeResult(err))
+	return err
+}
+
+func (c *orderCache) GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error) {
+	page, err := c.next.GetPage(ctx, key, field)
+	c.metrics.countCache("order_page", "get", readResult(err))
+	return page, err
+}
+
+func (c *orderCache) SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error {
+	err := c.next.SetPage(ctx, key, field, page, ttl)
+	c.metrics.countCache("order_page", "set", writeResult(err))
+	return err
+}
+
+// setOp Set с nil в кешах это инвалидация, считаем её отдельно
+func setOp(invalidate bool) string {
+	if invalidate {
+		return "invalidate"
+	}
+	return "set"
+}
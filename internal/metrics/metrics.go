@@ -0,0 +1,116 @@
+// Package metrics Prometheus метрики сервиса и обёртки, которые их собирают
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "commerce"
+
+// Результаты операций с кешем
+const (
+	CacheHit         = "hit"
+	CacheMiss        = "miss"
+	CacheNegativeHit = "negative_hit" // в кеше отметка, что записи нет
+	CacheError       = "error"
+	CacheOK          = "ok"
+)
+
+// Metrics держит свой registry, чтобы тесты не делили глобальный DefaultRegisterer
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	dbDuration *prometheus.HistogramVec
+
+	cacheOperations *prometheus.CounterVec
+
+	ordersCreated    prometheus.Counter
+	orderRevenue     prometheus.Counter
+	orderTransitions *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Repository method duration.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "method"}),
+
+		cacheOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "operations_total",
+			Help:      "Cache operations by cache, operation and result (hit, miss, error, ok).",
+		}, []string{"cache", "op", "result"}),
+
+		ordersCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "orders",
+			Name:      "created_total",
+			Help:      "Orders created.",
+		}),
+		orderRevenue: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "orders",
+			Name:      "revenue_total",
+			Help:      "Sum of created order totals in minor currency units.",
+		}),
+		orderTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "orders",
+			Name:      "status_transitions_total",
+			Help:      "Order status transitions by target status.",
+		}, []string{"status"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.cacheOperations,
+		m.ordersCreated,
+		m.orderRevenue,
+		m.orderTransitions,
+	)
+
+	return m
+}
+
+// RegisterDBStats добавляет статистику пула соединений database/sql
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// Handler отдаёт /metrics в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
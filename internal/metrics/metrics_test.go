@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+
+	r := gin.New()
+	r.Use(m.GinMiddleware())
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/missing"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/orders/:id", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.httpDuration))
+}
+
+// fakeOrderRepo реализует только то, что нужно тесту, остальные методы паникуют
+type fakeOrderRepo struct {
+	repositories.OrderRepository
+	err error
+}
+
+func (f *fakeOrderRepo) CreateOrder(_ context.Context, order *entity.Order) (*entity.Order, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return order, nil
+}
+
+func (f *fakeOrderRepo) TransitionStatus(context.Context, int64, entity.OrderStatus, entity.OrderStatus, int64) error {
+	return f.err
+}
+
+func TestInstrumentOrderRepository(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	repo := InstrumentOrderRepository(&fakeOrderRepo{}, m)
+	_, _ = repo.CreateOrder(ctx, &entity.Order{Total: 1500})
+	_, _ = repo.CreateOrder(ctx, &entity.Order{Total: 500})
+	_ = repo.TransitionStatus(ctx, 1, entity.OrderStatusPending, entity.OrderStatusPaid, 1)
+
+	failing := InstrumentOrderRepository(&fakeOrderRepo{err: repositories.ErrInsufficientStock}, m)
+	_, _ = failing.CreateOrder(ctx, &entity.Order{Total: 100})
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.ordersCreated))
+	assert.Equal(t, float64(2000), testutil.ToFloat64(m.orderRevenue))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.orderTransitions.WithLabelValues("paid")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.dbDuration))
+
+	expected := `
+		# HELP commerce_orders_created_total Orders created.
+		# TYPE commerce_orders_created_total counter
+		commerce_orders_created_total 2
+	`
+	assert.NoError(t, testutil.GatherAndCompare(m.Registry, strings.NewReader(expected), "commerce_orders_created_total"))
+}
+
+type fakeUserCache struct {
+	services.UserCache
+	err error
+}
+
+func (f *fakeUserCache) Get(context.Context, string) (*entity.User, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &entity.User{ID: 1}, nil
+}
+
+func (f *fakeUserCache) Set(context.Context, string, *entity.User, time.Duration) error {
+	return f.err
+}
+
+func TestInstrumentUserCache(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	_, _ = InstrumentUserCache(&fakeUserCache{}, m).Get(ctx, "user:1")
+	_, _ = InstrumentUserCache(&fakeUserCache{err: redis.Nil}, m).Get(ctx, "user:2")
+	_, _ = InstrumentUserCache(&fakeUserCache{err: errors.New("connection refused")}, m).Get(ctx, "user:3")
+	_, _ = InstrumentUserCache(&fakeUserCache{err: repositories.ErrUserNotFound}, m).Get(ctx, "user:4")
+	_ = InstrumentUserCache(&fakeUserCache{}, m).Set(ctx, "user:1", nil, 0)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheOperations.WithLabelValues("user", "get", CacheHit)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheOperations.WithLabelValues("user", "get", CacheMiss)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheOperations.WithLabelValues("user", "get", CacheError)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheOperations.WithLabelValues("user", "get", CacheNegativeHit)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheOperations.WithLabelValues("user", "invalidate", CacheOK)))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
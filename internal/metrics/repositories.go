@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+)
+
+func (m *Metrics) observeQuery(repository, method string, start time.Time) {
+	m.dbDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+}
+
+type userRepository struct {
+	next    repositories.UserRepository
+	metrics *Metrics
+}
+
+// InstrumentUserRepository замеряет время каждого метода репозитория пользователей
+func InstrumentUserRepository(next repositories.UserRepository, m *Metrics) repositories.UserRepository {
+	return &userRepository{next: next, metrics: m}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity.User, error) {
+	defer r.metrics.observeQuery("user", "Create", time.Now())
+	return r.next.Create(ctx, user)
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+	defer r.metrics.observeQuery("user", "GetByID", time.Now())
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	defer r.metrics.observeQuery("user", "GetByEmail", time.Now())
+	return r.next.GetByEmail(ctx, email)
+}
+
+func (r *userRepository) List(ctx context.Context, limit, offset int) ([]entity.User, error) {
+	defer r.metrics.observeQuery("user", "List", time.Now())
+	return r.next.List(ctx, limit, offset)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
+	defer r.metrics.observeQuery("user", "Update", time.Now())
+	return r.next.Update(ctx, user)
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	defer r.metrics.observeQuery("user", "UpdateRole", time.Now())
+	return r.next.UpdateRole(ctx, id, role)
+}
+
+func (r *userRepository) Delete(ctx context.Context, id int64) ([]int64, error) {
+	defer r.metrics.observeQuery("user", "Delete", time.Now())
+	return r.next.Delete(ctx, id)
+}
+
+type orderRepository struct {
+	next    repositories.OrderRepository
+	metrics *Metrics
+}
+
+// InstrumentOrderRepository замеряет методы репозитория заказов и считает бизнес метрики:
+// заказ учитывается только после коммита транзакции
+func InstrumentOrderRepository(next repositories.OrderRepository, m *Metrics) repositories.OrderRepository {
+	return &orderRepository{next: next, metrics: m}
+}
+
+func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	defer r.metrics.observeQuery("order", "CreateOrder", time.Now())
+
+	created, err := r.next.CreateOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	r.metrics.ordersCreated.Inc()
+	r.metrics.orderRevenue.Add(float64(created.Total))
+	return created, nil
+}
+
+func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	defer r.metrics.observeQuery("order", "GetOrderByID", time.Now())
+	return r.next.GetOrderByID(ctx, id)
+}
+
+func (r *orderRepository) ListOrdersByUserID(ctx context.Context, userID int64, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	defer r.metrics.observeQuery("order", "ListOrdersByUserID", time.Now())
+	return r.next.ListOrdersByUserID(ctx, userID, after, limit)
+}
+
+func (r *orderRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, error) {
+	defer r.metrics.observeQuery("order", "ListOrders", time.Now())
+	return r.next.ListOrders(ctx, filter)
+}
+
+func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order) error {
+	defer r.metrics.observeQuery("order", "UpdateOrder", time.Now())
+	return r.next.UpdateOrder(ctx, order)
+}
+
+func (r *orderRepository) DeleteOrderByID(ctx context.Context, id int64, allowed []entity.OrderStatus) error {
+	defer r.metrics.observeQuery("order", "DeleteOrderByID", time.Now())
+	return r.next.DeleteOrderByID(ctx, id, allowed)
+}
+
+func (r *orderRepository) TransitionStatus(ctx context.Context, id int64, from, to entity.OrderStatus, changedBy int64) error {
+	defer r.metrics.observeQuery("order", "TransitionStatus", time.Now())
+
+	if err := r.next.TransitionStatus(ctx, id, from, to, changedBy); err != nil {
+		return err
+	}
+
+	r.metrics.orderTransitions.WithLabelValues(string(to)).Inc()
+	return nil
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusChange, error) {
+	defer r.metrics.observeQuery("order", "GetStatusHistory", time.Now())
+	return r.next.GetStatusHistory(ctx, orderID)
+}
@@ -0,0 +1,6 @@
+// Package pb содержит код, сгенерированный из api/proto
+package pb
+
+//go:generate protoc -I ../../api/proto --go_out=../.. --go_opt=module=github.com/Belixk/CommerceTwo events/v1/events.proto
+//go:generate protoc -I ../../api/proto --go_out=../.. --go_opt=module=github.com/Belixk/CommerceTwo --go-grpc_out=../.. --go-grpc_opt=module=github.com/Belixk/CommerceTwo commerce/v1/user.proto commerce/v1/order.proto
+//go:generate protoc -I ../../api/proto --go_out=../.. --go_opt=module=github.com/Belixk/CommerceTwo cache/v1/cache.proto
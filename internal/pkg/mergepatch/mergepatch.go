@@ -0,0 +1,48 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidPatch = errors.New("merge patch must be a JSON object")
+
+// Apply применяет JSON Merge Patch (RFC 7386) к документу original.
+// null в патче удаляет поле, объекты сливаются рекурсивно, всё остальное (включая массивы) заменяется целиком
+func Apply(original, patch []byte) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(original, &doc); err != nil {
+		return nil, err
+	}
+
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	if _, ok := p.(map[string]any); !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	return json.Marshal(merge(doc, p))
+}
+
+func merge(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = merge(targetObj[key], value)
+	}
+	return targetObj
+}
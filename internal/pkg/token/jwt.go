@@ -0,0 +1,72 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type Claims struct {
+	UserID int64  `json:"uid"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+type JWTManager struct {
+	secret    []byte
+	accessTTL time.Duration
+}
+
+func NewJWTManager(secret string, accessTTL time.Duration) *JWTManager {
+	return &JWTManager{
+		secret:    []byte(secret),
+		accessTTL: accessTTL,
+	}
+}
+
+// NewAccessToken подписывает короткоживущий access токен для пользователя
+func (m *JWTManager) NewAccessToken(userID int64, role string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+func (m *JWTManager) ParseAccessToken(tokenStr string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (any, error) {
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// NewRefreshToken генерирует непрозрачный refresh токен, сам по себе он ничего не содержит
+func (m *JWTManager) NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (m *JWTManager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
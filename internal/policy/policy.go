@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("access denied")
+)
+
+type Permission string
+
+// Права на чужие данные. Со своими пользователь работает без отдельных прав
+const (
+	UsersRead        Permission = "users:read"
+	UsersList        Permission = "users:list"
+	UsersWrite       Permission = "users:write"
+	UsersDelete      Permission = "users:delete"
+	UsersManageRoles Permission = "users:manage_roles"
+	OrdersRead       Permission = "orders:read"
+	OrdersWrite      Permission = "orders:write"
+	OrdersDelete     Permission = "orders:delete"
+	OrdersStatus     Permission = "orders:status"
+	ProductsWrite    Permission = "products:write"
+)
+
+// Policy отвечает на вопрос "можно ли вызывающему это сделать", сервисы спрашивают её до действия
+type Policy struct {
+	roles map[string]map[Permission]struct{}
+}
+
+// New собирает политику из прав ролей, обычно они загружаются из таблицы role_permissions
+func New(rolePermissions map[string][]Permission) *Policy {
+	roles := make(map[string]map[Permission]struct{}, len(rolePermissions))
+	for role, perms := range rolePermissions {
+		set := make(map[Permission]struct{}, len(perms))
+		for _, perm := range perms {
+			set[perm] = struct{}{}
+		}
+		roles[role] = set
+	}
+	return &Policy{roles: roles}
+}
+
+func (p *Policy) RoleExists(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+func (p *Policy) Can(identity auth.Identity, perm Permission) bool {
+	_, ok := p.roles[identity.Role][perm]
+	return ok
+}
+
+// Require проверяет, что у вызывающего есть право perm
+func (p *Policy) Require(ctx context.Context, perm Permission) error {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !p.Can(identity, perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Authorize пускает владельца ресурса, остальным нужно право perm
+func (p *Policy) Authorize(ctx context.Context, perm Permission, ownerID int64) error {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if identity.UserID == ownerID || p.Can(identity, perm) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+type PermissionSource interface {
+	GetPermissions(ctx context.Context) (map[string][]string, error)
+}
+
+// Load читает права ролей из источника (таблица role_permissions) и собирает политику
+func Load(ctx context.Context, src PermissionSource) (*Policy, error) {
+	rows, err := src.GetPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rolePermissions := make(map[string][]Permission, len(rows))
+	for role, perms := range rows {
+		rolePermissions[role] = make([]Permission, 0, len(perms))
+		for _, perm := range perms {
+			rolePermissions[role] = append(rolePermissions[role], Permission(perm))
+		}
+	}
+	return New(rolePermissions), nil
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrCartQuantityLimit = errors.New("cart item quantity limit exceeded")
+
+// addItemScript проверка лимита и запись одним шагом, иначе параллельные добавления его обходят.
+// Нечисловое значение в поле считаем пустым, как и parseCartItems
+var addItemScript = redis.NewScript(`
+local quantity = (tonumber(redis.call("HGET", KEYS[1], ARGV[1])) or 0) + tonumber(ARGV[2])
+if quantity > tonumber(ARGV[3]) then
+	return -1
+end
+redis.call("HSET", KEYS[1], ARGV[1], quantity)
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return quantity`)
+
+// restoreItemsScript складывает возвращаемые товары с корзиной, обрезая каждую позицию до лимита.
+// ARGV: лимит, ttl в миллисекундах, дальше пары product_id, quantity
+var restoreItemsScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+for i = 3, #ARGV, 2 do
+	local quantity = (tonumber(redis.call("HGET", KEYS[1], ARGV[i])) or 0) + tonumber(ARGV[i + 1])
+	redis.call("HSET", KEYS[1], ARGV[i], math.min(quantity, limit))
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1`)
+
+type cartStore struct {
+	client *redis.Client
+}
+
+// NewCartStore создает хранилище корзин в Redis, корзина это hash product_id -> quantity
+func NewCartStore(client *redis.Client) *cartStore {
+	return &cartStore{client: client}
+}
+
+func (s *cartStore) Items(ctx context.Context, key string) (map[int64]int, error) {
+	values, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseCartItems(values), nil
+}
+
+// Add увеличивает количество товара и продлевает жизнь корзины.
+// Если в позиции станет больше limit, корзина не меняется
+func (s *cartStore) Add(ctx context.Context, key string, productID int64, quantity, limit int, ttl time.Duration) error {
+	total, err := addItemScript.Run(ctx, s.client, []string{key},
+		strconv.FormatInt(productID, 10), quantity, limit, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if total < 0 {
+		return ErrCartQuantityLimit
+	}
+	return nil
+}
+
+func (s *cartStore) SetQuantity(ctx context.Context, key string, productID int64, quantity int, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, strconv.FormatInt(productID, 10), quantity)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *cartStore) Remove(ctx context.Context, key string, productID int64) error {
+	return s.client.HDel(ctx, key, strconv.FormatInt(productID, 10)).Err()
+}
+
+// Claim атомарно забирает содержимое корзины и удаляет её,
+// из двух параллельных checkout товары получит только один
+func (s *cartStore) Claim(ctx context.Context, key string) (map[int64]int, error) {
+	var items *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		items = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseCartItems(items.Val()), nil
+}
+
+// Restore возвращает товары в корзину, складывая с тем, что успели добавить.
+// Позиция, которая превысила бы limit, обрезается до него: товары не теряются целиком
+func (s *cartStore) Restore(ctx context.Context, key string, items map[int64]int, limit int, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 2+2*len(items))
+	args = append(args, limit, ttl.Milliseconds())
+	for productID, quantity := range items {
+		args = append(args, strconv.FormatInt(productID, 10), quantity)
+	}
+	return restoreItemsScript.Run(ctx, s.client, []string{key}, args...).Err()
+}
+
+func parseCartItems(values map[string]string) map[int64]int {
+	items := make(map[int64]int, len(values))
+	for field, value := range values {
+		productID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity <= 0 {
+			continue
+		}
+		items[productID] = quantity
+	}
+	return items
+}
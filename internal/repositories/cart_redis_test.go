@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartStore_AddEnforcesLimit(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewCartStore(client)
+
+	require.NoError(t, store.Add(ctx, "cart:user:1", 10, 600, 1000, time.Hour))
+	assert.ErrorIs(t, store.Add(ctx, "cart:user:1", 10, 600, 1000, time.Hour), ErrCartQuantityLimit)
+	require.NoError(t, store.Add(ctx, "cart:user:1", 10, 400, 1000, time.Hour))
+	assert.Equal(t, time.Hour, server.TTL("cart:user:1"))
+
+	items, err := store.Items(ctx, "cart:user:1")
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int{10: 1000}, items)
+
+	// Параллельные добавления не проскакивают лимит вместе
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = store.Add(ctx, "cart:user:2", 10, 300, 1000, time.Hour)
+		}()
+	}
+	wg.Wait()
+
+	items, err = store.Items(ctx, "cart:user:2")
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int{10: 900}, items)
+}
+
+func TestCartStore_RestoreClampsToLimit(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewCartStore(client)
+
+	require.NoError(t, store.Add(ctx, "cart:user:1", 10, 800, 1000, time.Hour))
+	require.NoError(t, store.Restore(ctx, "cart:user:1", map[int64]int{10: 500, 11: 3}, 1000, 2*time.Hour))
+	assert.Equal(t, 2*time.Hour, server.TTL("cart:user:1"))
+
+	items, err := store.Items(ctx, "cart:user:1")
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int{10: 1000, 11: 3}, items)
+}
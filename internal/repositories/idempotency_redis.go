@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrIdempotencyLockLost ключ уже занят другим запросом: блокировка истекла и её перехватили
+var ErrIdempotencyLockLost = errors.New("idempotency lock is held by another request")
+
+// IdempotencyRecord запись о запросе с Idempotency-Key. Пока запрос выполняется,
+// Completed=false, Owner хранит токен владельца блокировки, а запись живёт недолго,
+// чтобы упавший процесс не держал ключ вечно
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Owner       string `json:"owner,omitempty"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Скрипты меняют ключ, только если в нём всё ещё блокировка этого владельца.
+// Блокировка сравнивается целиком, её значение владелец может построить сам
+var (
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	completeLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0`)
+
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+type idempotencyStore struct {
+	client *redis.Client
+}
+
+// NewIdempotencyStore создает хранилище ключей идемпотентности в Redis
+func NewIdempotencyStore(client *redis.Client) *idempotencyStore {
+	return &idempotencyStore{client: client}
+}
+
+// Begin занимает ключ через SET NX от имени owner. Если ключ уже занят, возвращает существующую запись
+func (s *idempotencyStore) Begin(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (*IdempotencyRecord, bool, error) {
+	lock, err := lockValue(fingerprint, owner)
+	if err != nil {
+		return nil, false, err
+	}
+
+	acquired, err := s.client.SetNX(ctx, key, lock, lockTTL).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if acquired {
+		return nil, true, nil
+	}
+
+	record, err := s.Get(ctx, key)
+	if err != nil {
+		// Ключ успел истечь между SETNX и GET, пусть вызывающий попробует снова
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func (s *idempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	val, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(val, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Extend продлевает блокировку, пока запрос ещё выполняется
+func (s *idempotencyStore) Extend(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) error {
+	lock, err := lockValue(fingerprint, owner)
+	if err != nil {
+		return err
+	}
+	return runLockScript(ctx, s.client, extendLockScript, key, lock, lockTTL.Milliseconds())
+}
+
+// Complete сохраняет ответ, дальше повторы с этим ключом его воспроизводят.
+// Если блокировку уже перехватили, чужую запись не трогает
+func (s *idempotencyStore) Complete(ctx context.Context, key, owner string, record *IdempotencyRecord, ttl time.Duration) error {
+	lock, err := lockValue(record.Fingerprint, owner)
+	if err != nil {
+		return err
+	}
+
+	record.Completed = true
+	record.Owner = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return runLockScript(ctx, s.client, completeLockScript, key, lock, data, ttl.Milliseconds())
+}
+
+// Release освобождает ключ, если запрос не удался и его можно повторить
+func (s *idempotencyStore) Release(ctx context.Context, key, fingerprint, owner string) error {
+	lock, err := lockValue(fingerprint, owner)
+	if err != nil {
+		return err
+	}
+	return runLockScript(ctx, s.client, releaseLockScript, key, lock)
+}
+
+func lockValue(fingerprint, owner string) ([]byte, error) {
+	return json.Marshal(IdempotencyRecord{Fingerprint: fingerprint, Owner: owner})
+}
+
+func runLockScript(ctx context.Context, client *redis.Client, script *redis.Script, key string, args ...any) error {
+	changed, err := script.Run(ctx, client, []string{key}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore_Ownership(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewIdempotencyStore(client)
+
+	_, acquired, err := store.Begin(ctx, "k", "fp", "first", time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.NoError(t, store.Extend(ctx, "k", "fp", "first", time.Minute))
+	assert.Equal(t, time.Minute, server.TTL("k"))
+
+	// Блокировка первого истекла, ключ занял второй запрос
+	server.FastForward(2 * time.Minute)
+	_, acquired, err = store.Begin(ctx, "k", "fp", "second", time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// Первый не может ни продлить, ни снять, ни перезаписать чужую блокировку
+	assert.ErrorIs(t, store.Extend(ctx, "k", "fp", "first", time.Minute), ErrIdempotencyLockLost)
+	assert.ErrorIs(t, store.Release(ctx, "k", "fp", "first"), ErrIdempotencyLockLost)
+	assert.ErrorIs(t, store.Complete(ctx, "k", "first", &IdempotencyRecord{Fingerprint: "fp", Status: 201}, time.Hour), ErrIdempotencyLockLost)
+
+	require.NoError(t, store.Complete(ctx, "k", "second", &IdempotencyRecord{Fingerprint: "fp", Status: 201}, time.Hour))
+	record, err := store.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, record.Completed)
+	assert.Empty(t, record.Owner)
+	assert.Equal(t, time.Hour, server.TTL("k"))
+
+	// Завершённую запись снять уже нельзя
+	assert.ErrorIs(t, store.Release(ctx, "k", "fp", "second"), ErrIdempotencyLockLost)
+}
@@ -2,20 +2,21 @@ package repositories
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
 	"github.com/Belixk/CommerceTwo/internal/entity"
 	"github.com/redis/go-redis/v9"
 )
 
 type orderCache struct {
 	client *redis.Client
+	codec  *codec.Codec
 }
 
 // NewOrderCache создает новый экземпляр кеша для заказов
-func NewOrderCache(client *redis.Client) *orderCache {
-	return &orderCache{client: client}
+func NewOrderCache(client *redis.Client, codec *codec.Codec) *orderCache {
+	return &orderCache{client: client, codec: codec}
 }
 
 func (c *orderCache) Get(ctx context.Context, key string) (*entity.Order, error) {
@@ -23,9 +24,12 @@ func (c *orderCache) Get(ctx context.Context, key string) (*entity.Order, error)
 	if err != nil {
 		return nil, err // Если ключа нет, вернется redis.Nil
 	}
+	if val == notFoundValue {
+		return nil, ErrOrderNotFound
+	}
 
 	var order entity.Order
-	if err := json.Unmarshal([]byte(val), &order); err != nil {
+	if err := c.codec.Decode([]byte(val), &order); err != nil {
 		return nil, err
 	}
 
@@ -38,10 +42,45 @@ func (c *orderCache) Set(ctx context.Context, key string, order *entity.Order, t
 		return c.client.Del(ctx, key).Err()
 	}
 
-	data, err := json.Marshal(order)
+	data, err := c.codec.Encode(order)
 	if err != nil {
 		return err
 	}
 
 	return c.client.Set(ctx, key, data, ttl).Err()
 }
+
+// SetNotFound запоминает, что заказа нет, Get потом вернёт ErrOrderNotFound
+func (c *orderCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return c.client.Set(ctx, key, notFoundValue, ttl).Err()
+}
+
+// GetPage читает закешированную страницу списка заказов, все страницы одного списка лежат в одном hash
+func (c *orderCache) GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error) {
+	val, err := c.client.HGet(ctx, key, field).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var page entity.OrderPage
+	if err := c.codec.Decode([]byte(val), &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// SetPage кладёт страницу в hash списка, инвалидация всего списка это Set(key, nil)
+func (c *orderCache) SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error {
+	data, err := c.codec.Encode(page)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, data)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
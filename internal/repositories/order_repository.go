@@ -4,20 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
 
 	"github.com/Belixk/CommerceTwo/internal/entity"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-var ErrOrderNotFound = errors.New("order with this id not found")
+var (
+	ErrOrderNotFound       = errors.New("order with this id not found")
+	ErrOrderStatusConflict = errors.New("order status was changed concurrently")
+	ErrOrderItemNotFound   = errors.New("order item does not belong to this order")
+	ErrOrderNotEditable    = errors.New("only pending orders can be changed")
+)
 
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
 	GetOrderByID(ctx context.Context, id int64) (*entity.Order, error)
-	GetOrderByUserID(ctx context.Context, id int64) (*entity.Order, error)
+	ListOrdersByUserID(ctx context.Context, userID int64, after *entity.OrderCursor, limit int) ([]entity.Order, error)
+	ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, error)
 	UpdateOrder(ctx context.Context, order *entity.Order) error
-	DeleteOrderByID(ctx context.Context, id int64) error
+	// DeleteOrderByID allowed, если не пуст, ограничивает статусы, в которых заказ можно удалить
+	DeleteOrderByID(ctx context.Context, id int64, allowed []entity.OrderStatus) error
+	TransitionStatus(ctx context.Context, id int64, from, to entity.OrderStatus, changedBy int64) error
+	GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusChange, error)
 }
 
 type orderRepository struct {
@@ -34,12 +45,20 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback() // кидаем в отложеное срабатывает откат бд, если вдруг что-то пойдёт не так
+	defer rollback(ctx, tx) // кидаем в отложеное срабатывает откат бд, если вдруг что-то пойдёт не так
+
+	// Резервируем товар до вставки заказа, при нехватке откатываем всё целиком
+	reserve := stockDelta{}
+	reserve.add(order.Items, 1)
+	if err := reserve.apply(ctx, tx); err != nil {
+		return nil, err
+	}
+
 	// Готовим сами товары и сохраняем
 	queryOrder := `
 		INSERT INTO orders (user_id, total, created_at, updated_at)
 		VALUES ($1, $2, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		RETURNING id, status, created_at, updated_at
 	`
 
 	err = tx.QueryRowxContext(ctx, queryOrder, order.UserID, order.Total).StructScan(order)
@@ -49,8 +68,8 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order)
 
 	// Теперь готовим сами предметы в заказе
 	queryItem := `
-		INSERT INTO order_items (order_id, name, quantity, price)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO order_items (order_id, product_id, name, quantity, price)
+		VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5)
 		RETURNING id
 	`
 
@@ -61,6 +80,7 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order)
 		err = tx.QueryRowxContext(
 			ctx, queryItem,
 			order.Items[i].OrderID,
+			order.Items[i].ProductID,
 			order.Items[i].Name,
 			order.Items[i].Quantity,
 			order.Items[i].Price,
@@ -70,6 +90,10 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order)
 		}
 	}
 
+	if err := addEvent(ctx, tx, entity.AggregateOrder, order.ID, entity.EventOrderCreated, order); err != nil {
+		return nil, err
+	}
+
 	// Если всё успешно прошло публикуем изменение в бд
 	if err := tx.Commit(); err != nil {
 		return nil, err
@@ -82,7 +106,7 @@ func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.O
 	var order entity.Order
 
 	queryOrder := `
-		SELECT id, user_id, total, created_at, updated_at
+		SELECT id, user_id, status, total, created_at, updated_at
 		FROM orders
 		WHERE id = $1
 	`
@@ -96,7 +120,7 @@ func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.O
 	// После берём сами предметы в заказе
 	var items []entity.OrderItem
 
-	queryItems := `SELECT id, order_id, name, quantity, price FROM order_items WHERE order_id = $1`
+	queryItems := `SELECT id, order_id, COALESCE(product_id, 0) AS product_id, name, quantity, price FROM order_items WHERE order_id = $1`
 	err = r.db.SelectContext(ctx, &items, queryItems, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -108,64 +132,259 @@ func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.O
 	return &order, nil
 }
 
-func (r *orderRepository) GetOrderByUserID(ctx context.Context, user_id int64) (*entity.Order, error) {
-	// Сначала берём сам заказ
-	var order entity.Order
+// ListOrdersByUserID отдаёт заказы пользователя от новых к старым, начиная после курсора
+func (r *orderRepository) ListOrdersByUserID(ctx context.Context, userID int64, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	orders := []entity.Order{}
 
-	queryOrder := `
-		SELECT id, user_id, total, created_at, updated_at
+	// Keyset пагинация: сравнение кортежей (created_at, id) стабильно даже при одинаковом created_at
+	var afterCreatedAt, afterID any
+	if after != nil {
+		afterCreatedAt, afterID = after.CreatedAt, after.ID
+	}
+
+	queryOrders := `
+		SELECT id, user_id, status, total, created_at, updated_at
 		FROM orders
 		WHERE user_id = $1
-		ORDER BY created_at DESC LIMIT 1
+		  AND ($2::timestamptz IS NULL OR (created_at, id) < ($2::timestamptz, $3::bigint))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
 	`
-	err := r.db.GetContext(ctx, &order, queryOrder, user_id)
+	err := r.db.SelectContext(ctx, &orders, queryOrders, userID, afterCreatedAt, afterID, limit)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrOrderNotFound
+		return nil, err
+	}
+
+	if err := r.attachItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Сортировать можно только по колонкам из этого списка, значение из запроса в SQL не попадает
+var orderSortColumns = map[string]string{
+	entity.OrderSortCreatedAt: "o.created_at",
+	entity.OrderSortTotal:     "o.total",
+}
+
+// ListOrders отдаёт заказы по фильтру с keyset пагинацией по (колонка сортировки, id)
+func (r *orderRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, error) {
+	column, ok := orderSortColumns[filter.SortBy]
+	if !ok {
+		return nil, entity.ErrInvalidFilter
+	}
+
+	var where whereBuilder
+	if filter.UserID != nil {
+		where.add("o.user_id = ?", *filter.UserID)
+	}
+	if filter.MinTotal != nil {
+		where.add("o.total >= ?", *filter.MinTotal)
+	}
+	if filter.MaxTotal != nil {
+		where.add("o.total <= ?", *filter.MaxTotal)
+	}
+	if filter.CreatedFrom != nil {
+		where.add("o.created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		where.add("o.created_at < ?", *filter.CreatedTo)
+	}
+	if filter.ItemName != "" {
+		where.add("EXISTS (SELECT 1 FROM order_items i WHERE i.order_id = o.id AND i.name ILIKE ?)", containsPattern(filter.ItemName))
+	}
+
+	direction, cmp := "ASC", ">"
+	if filter.Desc {
+		direction, cmp = "DESC", "<"
+	}
+	if filter.After != nil {
+		var value any = filter.After.CreatedAt
+		if filter.SortBy == entity.OrderSortTotal {
+			value = filter.After.Total
 		}
+		where.add("("+column+", o.id) "+cmp+" (?, ?)", value, filter.After.ID)
+	}
+
+	query := `
+		SELECT o.id, o.user_id, o.status, o.total, o.created_at, o.updated_at
+		FROM orders o
+		` + where.sql() + `
+		ORDER BY ` + column + ` ` + direction + `, o.id ` + direction + `
+		LIMIT ?
+	`
+	args := append(where.args, filter.Limit)
+
+	orders := []entity.Order{}
+	if err := r.db.SelectContext(ctx, &orders, r.db.Rebind(query), args...); err != nil {
 		return nil, err
 	}
 
-	// Теперь берём предметы в заказе
+	if err := r.attachItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// attachItems одним запросом подгружает предметы для пачки заказов
+func (r *orderRepository) attachItems(ctx context.Context, orders []entity.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(orders))
+	byID := make(map[int64]*entity.Order, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+		orders[i].Items = []entity.OrderItem{}
+		byID[orders[i].ID] = &orders[i]
+	}
+
 	var items []entity.OrderItem
 
-	queryItem := `SELECT id, order_id, name, quantity, price FROM order_items WHERE order_id = $1`
-	err = r.db.SelectContext(ctx, &items, queryItem, order.ID)
-	if err != nil {
-		return nil, err
+	queryItems := `SELECT id, order_id, COALESCE(product_id, 0) AS product_id, name, quantity, price FROM order_items WHERE order_id = ANY($1) ORDER BY id`
+	if err := r.db.SelectContext(ctx, &items, queryItems, pq.Array(ids)); err != nil {
+		return err
 	}
 
-	order.Items = items
-	return &order, nil
+	for _, item := range items {
+		if order, ok := byID[item.OrderID]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+	return nil
 }
 
+// UpdateOrder обновляет сумму и синхронизирует предметы заказа в одной транзакции:
+// предметы без id добавляются, с id обновляются, отсутствующие в заказе удаляются
 func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx)
+
+	// Статус проверяем здесь же: сервис видит его через кеш, а заказ могли оплатить или отменить параллельно
 	query := `
 		UPDATE orders
 		SET total = $1, updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $2 AND status = $3
 	`
-	result, err := r.db.ExecContext(ctx, query, order.Total, order.ID)
+	result, err := tx.ExecContext(ctx, query, order.Total, order.ID, entity.OrderStatusPending)
 	if err != nil {
 		return err
 	}
-	// Проверка на изменение строк, если 0, возвращаем, что не найден заказ
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return ErrOrderNotFound
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)", order.ID); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrOrderNotFound
+		}
+		return ErrOrderNotEditable
 	}
 
-	return nil
+	// Блокируем текущие предметы, чтобы параллельное обновление не смешало наборы
+	var current []entity.OrderItem
+	queryCurrent := `SELECT id, COALESCE(product_id, 0) AS product_id, quantity FROM order_items WHERE order_id = $1 FOR UPDATE`
+	if err := tx.SelectContext(ctx, &current, queryCurrent, order.ID); err != nil {
+		return err
+	}
+	existing := make(map[int64]bool, len(current))
+	for _, item := range current {
+		existing[item.ID] = true
+	}
+
+	// Резерв меняется только на разницу между старым и новым составом
+	delta := stockDelta{}
+	delta.add(current, -1)
+	delta.add(order.Items, 1)
+	if err := delta.apply(ctx, tx); err != nil {
+		return err
+	}
+
+	queryInsert := `
+		INSERT INTO order_items (order_id, product_id, name, quantity, price)
+		VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5)
+		RETURNING id
+	`
+	queryUpdate := `
+		UPDATE order_items
+		SET product_id = NULLIF($1::bigint, 0), name = $2, quantity = $3, price = $4
+		WHERE id = $5 AND order_id = $6
+	`
+
+	keep := make([]int64, 0, len(order.Items))
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderID = order.ID
+
+		if item.ID == 0 {
+			err = tx.QueryRowxContext(ctx, queryInsert, item.OrderID, item.ProductID, item.Name, item.Quantity, item.Price).Scan(&item.ID)
+			if err != nil {
+				return err
+			}
+			keep = append(keep, item.ID)
+			continue
+		}
+
+		// Чужой или уже удалённый предмет обновлять нельзя
+		if !existing[item.ID] {
+			return ErrOrderItemNotFound
+		}
+		if _, err := tx.ExecContext(ctx, queryUpdate, item.ProductID, item.Name, item.Quantity, item.Price, item.ID, order.ID); err != nil {
+			return err
+		}
+		keep = append(keep, item.ID)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM order_items WHERE order_id = $1 AND NOT (id = ANY($2))",
+		order.ID, pq.Array(keep),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := addEvent(ctx, tx, entity.AggregateOrder, order.ID, entity.EventOrderUpdated, order); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *orderRepository) DeleteOrderByID(ctx context.Context, id int64) error {
+func (r *orderRepository) DeleteOrderByID(ctx context.Context, id int64, allowed []entity.OrderStatus) error {
 	// начинаем транзакцию
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback() // Откатываем бд, если вдруг произошла ошибка
+	defer rollback(ctx, tx) // Откатываем бд, если вдруг произошла ошибка
+
+	// Блокируем заказ, чтобы статус не поменялся, пока возвращаем резерв
+	var current struct {
+		UserID int64              `db:"user_id"`
+		Status entity.OrderStatus `db:"status"`
+	}
+	err = tx.GetContext(ctx, &current, "SELECT user_id, status FROM orders WHERE id = $1 FOR UPDATE", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+	// Статус проверяем под блокировкой: заказ могли оплатить после того, как сервис его прочитал
+	if len(allowed) > 0 && !slices.Contains(allowed, current.Status) {
+		return ErrOrderStatusConflict
+	}
+	if holdsStock(current.Status) {
+		if err := releaseOrderStock(ctx, tx, id); err != nil {
+			return err
+		}
+	}
 
 	// Сначала удаляем предметы в заказе
 	_, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = $1", id)
@@ -185,6 +404,78 @@ func (r *orderRepository) DeleteOrderByID(ctx context.Context, id int64) error {
 		return ErrOrderNotFound
 	}
 
+	payload := entity.OrderDeletedPayload{ID: id, UserID: current.UserID}
+	if err := addEvent(ctx, tx, entity.AggregateOrder, id, entity.EventOrderDeleted, payload); err != nil {
+		return err
+	}
+
 	// Публикуем изменение в бд
 	return tx.Commit()
 }
+
+// TransitionStatus меняет статус, только если заказ всё ещё в статусе from, и пишет историю в той же транзакции
+func (r *orderRepository) TransitionStatus(ctx context.Context, id int64, from, to entity.OrderStatus, changedBy int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx)
+
+	var userID int64
+	err = tx.GetContext(ctx, &userID,
+		"UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3 RETURNING user_id",
+		to, id, from,
+	)
+	// Нет строк: заказа нет или его статус успели поменять параллельно
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)", id); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrOrderNotFound
+		}
+		return ErrOrderStatusConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	// Отменённый или возвращённый до отгрузки заказ больше не держит товар
+	if releasesStock(from, to) {
+		if err := releaseOrderStock(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	queryHistory := `
+		INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`
+	if _, err := tx.ExecContext(ctx, queryHistory, id, from, to, changedBy); err != nil {
+		return err
+	}
+
+	payload := entity.OrderStatusChangedPayload{ID: id, UserID: userID, From: from, To: to, ChangedBy: changedBy}
+	if err := addEvent(ctx, tx, entity.AggregateOrder, id, entity.EventOrderStatusChanged, payload); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusChange, error) {
+	history := []entity.OrderStatusChange{}
+
+	query := `
+		SELECT id, order_id, from_status, to_status, changed_by, changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+	if err := r.db.SelectContext(ctx, &history, query, orderID); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,310 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderRepository_ListOrdersByUserID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	after := &entity.OrderCursor{CreatedAt: createdAt.Add(time.Hour), ID: 10}
+
+	orderRows := sqlmock.NewRows([]string{"id", "user_id", "total", "created_at", "updated_at"}).
+		AddRow(int64(9), int64(1), int64(300), createdAt, createdAt).
+		AddRow(int64(8), int64(1), int64(100), createdAt, createdAt)
+	mock.ExpectQuery("SELECT (.+) FROM orders WHERE user_id = \\$1 (.+) ORDER BY created_at DESC, id DESC LIMIT \\$4").
+		WithArgs(int64(1), after.CreatedAt, after.ID, 3).
+		WillReturnRows(orderRows)
+
+	itemRows := sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price"}).
+		AddRow(int64(1), int64(9), "book", 3, int64(100))
+	mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = ANY\\(\\$1\\)").
+		WillReturnRows(itemRows)
+
+	orders, err := repo.ListOrdersByUserID(context.Background(), 1, after, 3)
+
+	assert.NoError(t, err)
+	assert.Len(t, orders, 2)
+	assert.Len(t, orders[0].Items, 1)
+	assert.Empty(t, orders[1].Items)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_ListOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+	userID, minTotal := int64(1), int64(100)
+	filter := entity.OrderFilter{
+		UserID:   &userID,
+		MinTotal: &minTotal,
+		ItemName: "50%_off",
+		SortBy:   entity.OrderSortTotal,
+		After:    &entity.OrderCursor{Total: 500, ID: 7},
+		Limit:    11,
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM orders o WHERE o.user_id = \\$1 AND o.total >= \\$2 AND EXISTS \\((.+) ILIKE \\$3\\) AND \\(o.total, o.id\\) > \\(\\$4, \\$5\\) ORDER BY o.total ASC, o.id ASC LIMIT \\$6").
+		WithArgs(userID, minTotal, `%50\%\_off%`, int64(500), int64(7), 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "total", "created_at", "updated_at"}))
+
+	orders, err := repo.ListOrders(context.Background(), filter)
+
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_UpdateOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+
+	t.Run("inserts new, updates kept and deletes missing items", func(t *testing.T) {
+		order := &entity.Order{
+			ID:    5,
+			Total: 700,
+			Items: []entity.OrderItem{
+				{ID: 1, ProductID: 10, Name: "book", Quantity: 2, Price: 100},
+				{ProductID: 11, Name: "pen", Quantity: 5, Price: 100},
+			},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE orders SET total = \\$1(.+)WHERE id = \\$2 AND status = \\$3").WithArgs(int64(700), int64(5), entity.OrderStatusPending).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = \\$1 FOR UPDATE").WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "quantity"}).
+				AddRow(int64(1), int64(10), 3).
+				AddRow(int64(2), int64(12), 1))
+		// book: 3 -> 2 возвращаем одну, pen новый резервируем, предмет 2 удалён и возвращается
+		mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(1, int64(10)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE products SET stock = stock - \\$1 WHERE id = \\$2 AND stock >= \\$1").WithArgs(5, int64(11)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(1, int64(12)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE order_items SET (.+) WHERE id = \\$5 AND order_id = \\$6").
+			WithArgs(int64(10), "book", 2, int64(100), int64(1), int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO order_items").WithArgs(int64(5), int64(11), "pen", 5, int64(100)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(3)))
+		mock.ExpectExec("DELETE FROM order_items WHERE order_id = \\$1 AND NOT \\(id = ANY\\(\\$2\\)\\)").
+			WithArgs(int64(5), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateOrder, int64(5), entity.EventOrderUpdated, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.UpdateOrder(context.Background(), order)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), order.Items[1].ID)
+		assert.Equal(t, int64(5), order.Items[1].OrderID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects item from another order", func(t *testing.T) {
+		order := &entity.Order{ID: 5, Items: []entity.OrderItem{{ID: 42, Name: "book", Quantity: 1, Price: 100}}}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE orders SET total = \\$1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT (.+) FROM order_items").WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "quantity"}).AddRow(int64(1), int64(0), 1))
+		mock.ExpectRollback()
+
+		err := repo.UpdateOrder(context.Background(), order)
+
+		assert.ErrorIs(t, err, ErrOrderItemNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("order paid concurrently is not edited", func(t *testing.T) {
+		order := &entity.Order{ID: 5, Items: []entity.OrderItem{{ID: 1, ProductID: 10, Name: "book", Quantity: 1, Price: 100}}}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE orders SET total = \\$1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(int64(5)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := repo.UpdateOrder(context.Background(), order)
+
+		assert.ErrorIs(t, err, ErrOrderNotEditable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepository_CreateOrder_InsufficientStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+	order := &entity.Order{
+		UserID: 1,
+		Total:  500,
+		Items: []entity.OrderItem{
+			{ProductID: 11, Name: "pen", Quantity: 1, Price: 100},
+			{ProductID: 10, Name: "book", Quantity: 2, Price: 100},
+			{ProductID: 11, Name: "pen", Quantity: 1, Price: 100},
+		},
+	}
+
+	// Товары списываются по возрастанию id, одинаковые позиции суммируются
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products SET stock = stock - \\$1 WHERE id = \\$2 AND stock >= \\$1").WithArgs(2, int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE products SET stock = stock - \\$1 WHERE id = \\$2 AND stock >= \\$1").WithArgs(2, int64(11)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	res, err := repo.CreateOrder(context.Background(), order)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_DeleteOrderByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+
+	t.Run("pending order returns stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT user_id, status FROM orders WHERE id = \\$1 FOR UPDATE").WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(1), "pending"))
+		mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = \\$1").WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "name", "quantity", "price"}).
+				AddRow(int64(1), int64(5), int64(10), "book", 2, int64(100)).
+				AddRow(int64(2), int64(5), int64(0), "legacy", 1, int64(50)))
+		mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(2, int64(10)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM order_items").WithArgs(int64(5)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM orders").WithArgs(int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateOrder, int64(5), entity.EventOrderDeleted, []byte(`{"id":5,"user_id":1}`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.DeleteOrderByID(context.Background(), 5, nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("shipped order keeps stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT user_id, status FROM orders").WithArgs(int64(6)).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(1), "shipped"))
+		mock.ExpectExec("DELETE FROM order_items").WithArgs(int64(6)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM orders").WithArgs(int64(6)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.DeleteOrderByID(context.Background(), 6, nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("order paid after the owner read it is kept", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT user_id, status FROM orders").WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(1), "paid"))
+		mock.ExpectRollback()
+
+		allowed := []entity.OrderStatus{entity.OrderStatusPending, entity.OrderStatusCancelled}
+		assert.ErrorIs(t, repo.DeleteOrderByID(context.Background(), 7, allowed), ErrOrderStatusConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepository_TransitionStatus_CancelReleasesStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE orders SET status = \\$1(.+)RETURNING user_id").WithArgs(entity.OrderStatusCancelled, int64(5), entity.OrderStatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+	mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = \\$1").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "name", "quantity", "price"}).
+			AddRow(int64(1), int64(5), int64(10), "book", 2, int64(100)))
+	mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(2, int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO order_status_history").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateOrder, int64(5), entity.EventOrderStatusChanged, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.TransitionStatus(context.Background(), 5, entity.OrderStatusPending, entity.OrderStatusCancelled, 1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_TransitionStatus_RefundReleasesStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOrderRepository(sqlx.NewDb(db, "postgres"))
+
+	t.Run("paid order refunded before shipment returns stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE orders SET status = \\$1(.+)RETURNING user_id").WithArgs(entity.OrderStatusRefunded, int64(5), entity.OrderStatusPaid).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+		mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = \\$1").WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "name", "quantity", "price"}).
+				AddRow(int64(1), int64(5), int64(10), "book", 2, int64(100)))
+		mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(2, int64(10)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO order_status_history").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.TransitionStatus(context.Background(), 5, entity.OrderStatusPaid, entity.OrderStatusRefunded, 1)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delivered order refund keeps stock as is", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE orders SET status = \\$1(.+)RETURNING user_id").WithArgs(entity.OrderStatusRefunded, int64(5), entity.OrderStatusDelivered).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+		mock.ExpectExec("INSERT INTO order_status_history").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.TransitionStatus(context.Background(), 5, entity.OrderStatusDelivered, entity.OrderStatusRefunded, 1)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
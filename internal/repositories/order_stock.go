@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrInsufficientStock на складе не хватает товара под заказ, оборачивается с id товара
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// stockDelta сколько единиц каждого товара нужно зарезервировать (>0) или вернуть на склад (<0)
+type stockDelta map[int64]int
+
+func (d stockDelta) add(items []entity.OrderItem, sign int) {
+	for _, item := range items {
+		if item.ProductID != 0 {
+			d[item.ProductID] += sign * item.Quantity
+		}
+	}
+}
+
+// apply меняет остатки в транзакции заказа. Товары обходятся по возрастанию id,
+// чтобы параллельные заказы блокировали строки products в одном порядке и не ловили deadlock
+func (d stockDelta) apply(ctx context.Context, tx *sqlx.Tx) error {
+	ids := make([]int64, 0, len(d))
+	for id, qty := range d {
+		if qty != 0 {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		qty := d[id]
+		if qty < 0 {
+			if _, err := tx.ExecContext(ctx, "UPDATE products SET stock = stock + $1 WHERE id = $2", -qty, id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Условное списание: строка обновится, только если остатка хватает
+		result, err := tx.ExecContext(ctx, "UPDATE products SET stock = stock - $1 WHERE id = $2 AND stock >= $1", qty, id)
+		if err != nil {
+			return err
+		}
+		rows, _ := result.RowsAffected()
+		if rows == 0 {
+			return fmt.Errorf("%w: product %d", ErrInsufficientStock, id)
+		}
+	}
+	return nil
+}
+
+// releaseOrderStock возвращает на склад всё, что зарезервировано предметами заказа
+func releaseOrderStock(ctx context.Context, tx *sqlx.Tx, orderID int64) error {
+	var items []entity.OrderItem
+	query := `SELECT id, order_id, COALESCE(product_id, 0) AS product_id, name, quantity, price FROM order_items WHERE order_id = $1`
+	if err := tx.SelectContext(ctx, &items, query, orderID); err != nil {
+		return err
+	}
+
+	delta := stockDelta{}
+	delta.add(items, -1)
+	return delta.apply(ctx, tx)
+}
+
+// releaseOrdersStock то же для нескольких заказов: резервы суммируются, и строки products
+// блокируются одним проходом по возрастанию id
+func releaseOrdersStock(ctx context.Context, tx *sqlx.Tx, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+
+	var items []entity.OrderItem
+	query := `SELECT id, order_id, COALESCE(product_id, 0) AS product_id, name, quantity, price FROM order_items WHERE order_id = ANY($1)`
+	if err := tx.SelectContext(ctx, &items, query, pq.Array(orderIDs)); err != nil {
+		return err
+	}
+
+	delta := stockDelta{}
+	delta.add(items, -1)
+	return delta.apply(ctx, tx)
+}
+
+// holdsStock резерв держат заказы, которые ещё не отгружены и не отменены
+func holdsStock(status entity.OrderStatus) bool {
+	return status == entity.OrderStatusPending || status == entity.OrderStatusPaid
+}
+
+// releasesStock переход, после которого товар возвращается на склад: заказ закрыт, не будучи отгруженным.
+// Отгрузка тоже снимает резерв, но товар при этом уходит покупателю
+func releasesStock(from, to entity.OrderStatus) bool {
+	closed := to == entity.OrderStatusCancelled || to == entity.OrderStatusRefunded
+	return closed && holdsStock(from)
+}
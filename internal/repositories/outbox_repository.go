@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OutboxRepository interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]entity.Event, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	MarkDead(ctx context.Context, id int64, reason string) error
+}
+
+type outboxRepository struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepository(db *sqlx.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// addEvent пишет событие в outbox в транзакции изменения: либо сохранится и то и другое, либо ничего
+func addEvent(ctx context.Context, tx *sqlx.Tx, aggregateType string, aggregateID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload, created_at, available_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`
+	_, err = tx.ExecContext(ctx, query, aggregateType, aggregateID, eventType, data)
+	return err
+}
+
+// Claim забирает пачку готовых к отправке событий и откладывает их на время lease.
+// Если relay упадёт посреди отправки, события снова станут доступны после lease,
+// а SKIP LOCKED даёт нескольким экземплярам relay разбирать outbox без пересечений
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]entity.Event, error) {
+	events := []entity.Event{}
+
+	query := `
+		UPDATE outbox
+		SET available_at = NOW() + make_interval(secs => $2), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND available_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, aggregate_type, aggregate_id, event_type, payload, attempts, created_at
+	`
+	if err := r.db.SelectContext(ctx, &events, query, limit, lease.Seconds()); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не гарантирует порядок, а публиковать нужно в порядке записи
+	slices.SortFunc(events, func(a, b entity.Event) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET status = 'published', published_at = NOW(), last_error = NULL WHERE id = $1",
+		id,
+	)
+	return err
+}
+
+// MarkFailed оставляет событие в очереди до retryAt
+func (r *outboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET last_error = $1, available_at = $2 WHERE id = $3",
+		reason, retryAt, id,
+	)
+	return err
+}
+
+// MarkDead убирает событие из очереди после исчерпания попыток, разбирать его придётся руками
+func (r *outboxRepository) MarkDead(ctx context.Context, id int64, reason string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET status = 'dead', last_error = $1 WHERE id = $2",
+		reason, id,
+	)
+	return err
+}
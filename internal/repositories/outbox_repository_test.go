@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRepository_Claim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewOutboxRepository(sqlx.NewDb(db, "postgres"))
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "attempts", "created_at"}).
+		AddRow(int64(8), entity.AggregateOrder, int64(5), entity.EventOrderUpdated, []byte(`{"id":5}`), 1, now).
+		AddRow(int64(7), entity.AggregateOrder, int64(5), entity.EventOrderCreated, []byte(`{"id":5}`), 2, now)
+	mock.ExpectQuery("UPDATE outbox SET (.+) FOR UPDATE SKIP LOCKED").WithArgs(10, float64(60)).WillReturnRows(rows)
+
+	events, err := repo.Claim(context.Background(), 10, time.Minute)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(7), events[0].ID)
+	assert.JSONEq(t, `{"id":5}`, string(events[0].Payload))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrSKUExists       = errors.New("sku already exists")
+)
+
+type ProductRepository interface {
+	Create(ctx context.Context, product *entity.Product) (*entity.Product, error)
+	GetByID(ctx context.Context, id int64) (*entity.Product, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]entity.Product, error)
+	List(ctx context.Context, activeOnly bool, limit, offset int) ([]entity.Product, error)
+	Update(ctx context.Context, product *entity.Product) error
+	AdjustStock(ctx context.Context, id int64, delta int) (int, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type productRepository struct {
+	db *sqlx.DB
+}
+
+func NewProductRepository(db *sqlx.DB) ProductRepository {
+	return &productRepository{db: db}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *entity.Product) (*entity.Product, error) {
+	query := `
+		INSERT INTO products (sku, name, description, price, active, stock, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+	err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		product.SKU,
+		product.Name,
+		product.Description,
+		product.Price,
+		product.Active,
+		product.Stock,
+	).StructScan(product)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrSKUExists
+		}
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id int64) (*entity.Product, error) {
+	var product entity.Product
+
+	query := `
+		SELECT id, sku, name, description, price, active, stock, created_at, updated_at
+		FROM products
+		WHERE id = $1
+	`
+	err := r.db.GetContext(ctx, &product, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetByIDs отдаёт найденные товары, отсутствующие id просто не попадают в результат
+func (r *productRepository) GetByIDs(ctx context.Context, ids []int64) ([]entity.Product, error) {
+	products := []entity.Product{}
+
+	query := `
+		SELECT id, sku, name, description, price, active, stock, created_at, updated_at
+		FROM products
+		WHERE id = ANY($1)
+	`
+	if err := r.db.SelectContext(ctx, &products, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (r *productRepository) List(ctx context.Context, activeOnly bool, limit, offset int) ([]entity.Product, error) {
+	products := []entity.Product{}
+
+	query := `
+		SELECT id, sku, name, description, price, active, stock, created_at, updated_at
+		FROM products
+		WHERE active OR NOT $1
+		ORDER BY id
+		LIMIT $2 OFFSET $3
+	`
+	if err := r.db.SelectContext(ctx, &products, query, activeOnly, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// Update меняет карточку товара. Остаток здесь не трогаем: абсолютное значение затёрло бы
+// резервы заказов, сделанные параллельно, для этого есть AdjustStock
+func (r *productRepository) Update(ctx context.Context, product *entity.Product) error {
+	query := `
+		UPDATE products
+		SET sku = :sku, name = :name, description = :description, price = :price, active = :active
+		WHERE id = :id
+	`
+
+	result, err := r.db.NamedExecContext(ctx, query, product)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrSKUExists
+		}
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrProductNotFound
+	}
+
+	return nil
+}
+
+// AdjustStock приход (delta > 0) или списание (delta < 0) относительно текущего остатка,
+// возвращает новый остаток. Уйти в минус нельзя
+func (r *productRepository) AdjustStock(ctx context.Context, id int64, delta int) (int, error) {
+	var stock int
+	query := `UPDATE products SET stock = stock + $1 WHERE id = $2 AND stock + $1 >= 0 RETURNING stock`
+	err := r.db.QueryRowxContext(ctx, query, delta, id).Scan(&stock)
+	if err == nil {
+		return stock, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// Строка не обновилась: либо товара нет, либо остатка не хватило
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", id); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrProductNotFound
+	}
+	return 0, fmt.Errorf("%w: product %d", ErrInsufficientStock, id)
+}
+
+func (r *productRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
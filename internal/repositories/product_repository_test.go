@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductRepository_UpdateKeepsStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectExec("UPDATE products SET sku = \\$1, name = \\$2, description = \\$3, price = \\$4, active = \\$5 WHERE id = \\$6").
+		WithArgs("A-1", "book", "", int64(100), true, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.Update(context.Background(), &entity.Product{ID: 1, SKU: "A-1", Name: "book", Price: 100, Active: true, Stock: 5})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductRepository_AdjustStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(sqlx.NewDb(db, "postgres"))
+	query := "UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2 AND stock \\+ \\$1 >= 0 RETURNING stock"
+
+	mock.ExpectQuery(query).WithArgs(3, int64(1)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(8))
+	stock, err := repo.AdjustStock(context.Background(), 1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, stock)
+
+	mock.ExpectQuery(query).WithArgs(-10, int64(1)).WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	_, err = repo.AdjustStock(context.Background(), 1, -10)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	mock.ExpectQuery(query).WithArgs(1, int64(2)).WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	_, err = repo.AdjustStock(context.Background(), 2, 1)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import "strings"
+
+// whereBuilder собирает WHERE из условий с плейсхолдерами "?".
+// Значения всегда уходят параметрами, в текст запроса попадают только условия из кода
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+func (b *whereBuilder) add(cond string, args ...any) {
+	b.conds = append(b.conds, cond)
+	b.args = append(b.args, args...)
+}
+
+func (b *whereBuilder) sql() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// likeEscaper экранирует спецсимволы LIKE, чтобы поиск по подстроке был буквальным
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
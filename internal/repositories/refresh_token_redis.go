@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type refreshTokenStore struct {
+	client *redis.Client
+}
+
+// NewRefreshTokenStore создает хранилище refresh токенов в Redis
+func NewRefreshTokenStore(client *redis.Client) *refreshTokenStore {
+	return &refreshTokenStore{client: client}
+}
+
+// В Redis храним только хеш токена, чтобы утечка дампа не давала рабочих токенов
+func refreshTokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "refresh:" + hex.EncodeToString(sum[:])
+}
+
+func (s *refreshTokenStore) Save(ctx context.Context, token string, userID int64, ttl time.Duration) error {
+	return s.client.Set(ctx, refreshTokenKey(token), userID, ttl).Err()
+}
+
+// Consume атомарно читает и удаляет токен, поэтому каждый refresh токен одноразовый
+func (s *refreshTokenStore) Consume(ctx context.Context, token string) (int64, error) {
+	val, err := s.client.GetDel(ctx, refreshTokenKey(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrRefreshTokenNotFound
+		}
+		return 0, err
+	}
+
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func (s *refreshTokenStore) Delete(ctx context.Context, token string) error {
+	return s.client.Del(ctx, refreshTokenKey(token)).Err()
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRepository interface {
+	GetPermissions(ctx context.Context) (map[string][]string, error)
+}
+
+type roleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRoleRepository(db *sqlx.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// GetPermissions возвращает права всех ролей, роль без прав тоже попадает в результат
+func (r *roleRepository) GetPermissions(ctx context.Context) (map[string][]string, error) {
+	var rows []struct {
+		Role       string  `db:"name"`
+		Permission *string `db:"permission"`
+	}
+
+	query := `
+		SELECT r.name, rp.permission
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+	`
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string)
+	for _, row := range rows {
+		if _, ok := result[row.Role]; !ok {
+			result[row.Role] = nil
+		}
+		if row.Permission != nil {
+			result[row.Role] = append(result[row.Role], *row.Permission)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/Belixk/CommerceTwo/internal/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// rollback откатывает транзакцию в defer. После Commit откат ничего не делает,
+// а реальную ошибку отката пишем в лог запроса, вернуть её уже некуда
+func rollback(ctx context.Context, tx *sqlx.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logging.FromContext(ctx).WarnContext(ctx, "transaction rollback failed", slog.Any("error", err))
+	}
+}
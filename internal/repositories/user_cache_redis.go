@@ -2,19 +2,23 @@ package repositories
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/cache/codec"
 	"github.com/Belixk/CommerceTwo/internal/entity"
 	"github.com/redis/go-redis/v9"
 )
 
+// notFoundValue отметка закешированного промаха. JSON с неё не начинается, с записью не спутать
+const notFoundValue = "!not_found"
+
 type userCache struct {
 	client *redis.Client
+	codec  *codec.Codec
 }
 
-func NewUserCache(client *redis.Client) *userCache {
-	return &userCache{client: client}
+func NewUserCache(client *redis.Client, codec *codec.Codec) *userCache {
+	return &userCache{client: client, codec: codec}
 }
 
 func (c *userCache) Get(ctx context.Context, key string) (*entity.User, error) {
@@ -22,10 +26,13 @@ func (c *userCache) Get(ctx context.Context, key string) (*entity.User, error) {
 	if err != nil {
 		return nil, err
 	}
+	if val == notFoundValue {
+		return nil, ErrUserNotFound
+	}
 
 	var user entity.User
 
-	if err := json.Unmarshal([]byte(val), &user); err != nil {
+	if err := c.codec.Decode([]byte(val), &user); err != nil {
 		return nil, err
 	}
 
@@ -33,10 +40,20 @@ func (c *userCache) Get(ctx context.Context, key string) (*entity.User, error) {
 }
 
 func (c *userCache) Set(ctx context.Context, key string, user *entity.User, ttl time.Duration) error {
-	data, err := json.Marshal(user)
+	// nil означает инвалидацию, как и в кеше заказов
+	if user == nil {
+		return c.client.Del(ctx, key).Err()
+	}
+
+	data, err := c.codec.Encode(user)
 	if err != nil {
 		return err
 	}
 
 	return c.client.Set(ctx, key, data, ttl).Err()
 }
+
+// SetNotFound запоминает, что пользователя нет, Get потом вернёт ErrUserNotFound
+func (c *userCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return c.client.Set(ctx, key, notFoundValue, ttl).Err()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/Belixk/CommerceTwo/internal/entity"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
@@ -42,4 +43,67 @@ func TestUserRepository_GetByID(t *testing.T) {
 		assert.Nil(t, user)
 		assert.Equal(t, ErrUserNotFound, err)
 	})
-}
\ No newline at end of file
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(sqlx.NewDb(db, "postgres"))
+
+	ordersQuery := "SELECT id, status FROM orders WHERE user_id = \\$1 ORDER BY id FOR UPDATE"
+
+	t.Run("writes user.deleted in the same transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(ordersQuery).WithArgs(int64(3)).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}))
+		mock.ExpectExec("DELETE FROM users WHERE id = \\$1").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateUser, int64(3), entity.EventUserDeleted, []byte(`{"id":3}`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		orderIDs, err := repo.Delete(context.Background(), 3)
+		assert.NoError(t, err)
+		assert.Empty(t, orderIDs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cascaded orders return stock and write order.deleted", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(ordersQuery).WithArgs(int64(3)).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).
+			AddRow(int64(7), entity.OrderStatusPaid).
+			AddRow(int64(8), entity.OrderStatusDelivered))
+		// Резерв держит только оплаченный заказ 7
+		mock.ExpectQuery("SELECT (.+) FROM order_items WHERE order_id = ANY\\(\\$1\\)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "name", "quantity", "price"}).
+				AddRow(int64(1), int64(7), int64(10), "book", 2, int64(100)))
+		mock.ExpectExec("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2").WithArgs(2, int64(10)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM users WHERE id = \\$1").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateOrder, int64(7), entity.EventOrderDeleted, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateOrder, int64(8), entity.EventOrderDeleted, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(entity.AggregateUser, int64(3), entity.EventUserDeleted, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		orderIDs, err := repo.Delete(context.Background(), 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{7, 8}, orderIDs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing user writes no event", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(ordersQuery).WithArgs(int64(4)).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}))
+		mock.ExpectExec("DELETE FROM users").WithArgs(int64(4)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := repo.Delete(context.Background(), 4)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -20,8 +20,10 @@ type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) (*entity.User, error)
 	GetByID(ctx context.Context, id int64) (*entity.User, error)
 	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	List(ctx context.Context, limit, offset int) ([]entity.User, error)
 	Update(ctx context.Context, user *entity.User) error
-	Delete(ctx context.Context, id int64) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	Delete(ctx context.Context, id int64) ([]int64, error)
 }
 
 type userRepository struct {
@@ -33,12 +35,18 @@ func NewUserRepository(db *sqlx.DB) UserRepository {
 }
 
 func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity.User, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(ctx, tx)
+
 	query := `
 		INSERT INTO users (first_name, last_name, email, age, password_hash, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		RETURNING id, role, created_at, updated_at
 	`
-	err := r.db.QueryRowxContext(
+	err = tx.QueryRowxContext(
 		ctx,
 		query,
 		user.FirstName,
@@ -55,12 +63,20 @@ func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity
 		return nil, err
 	}
 
+	// password_hash в событие не попадает, у него json:"-"
+	if err := addEvent(ctx, tx, entity.AggregateUser, user.ID, entity.EventUserCreated, user); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
 func (r *userRepository) GetByID(ctx context.Context, id int64) (*entity.User, error) {
 	var user entity.User
-	query := `SELECT id, first_name, last_name, email, age, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT id, first_name, last_name, email, role, age, created_at, updated_at FROM users WHERE id = $1`
 
 	err := r.db.GetContext(ctx, &user, query, id)
 	if err != nil {
@@ -76,7 +92,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.
 	var user entity.User
 
 	query := `
-		SELECT id, first_name, last_name, email, age, created_at, updated_at
+		SELECT id, first_name, last_name, email, COALESCE(password_hash, '') AS password_hash, role, age, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -92,6 +108,23 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.
 	return &user, nil
 }
 
+func (r *userRepository) List(ctx context.Context, limit, offset int) ([]entity.User, error) {
+	users := []entity.User{}
+
+	query := `
+		SELECT id, first_name, last_name, email, role, age, created_at, updated_at
+		FROM users
+		ORDER BY id
+		LIMIT $1 OFFSET $2
+	`
+
+	if err := r.db.SelectContext(ctx, &users, query, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 	query := `
 		UPDATE users
@@ -101,6 +134,10 @@ func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 
 	result, err := r.db.NamedExecContext(ctx, query, user)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailExists
+		}
 		return err
 	}
 
@@ -112,10 +149,10 @@ func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 	return nil
 }
 
-func (r *userRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+func (r *userRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	query := `UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2`
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := r.db.ExecContext(ctx, query, role, id)
 	if err != nil {
 		return err
 	}
@@ -124,5 +161,62 @@ func (r *userRepository) Delete(ctx context.Context, id int64) error {
 	if rows == 0 {
 		return ErrUserNotFound
 	}
+
 	return nil
 }
+
+// Delete удаляет пользователя, его заказы уходят каскадом. Возвращает id удалённых заказов,
+// чтобы вызывающий сбросил их из кеша
+func (r *userRepository) Delete(ctx context.Context, id int64) ([]int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(ctx, tx)
+
+	// Каскад удалит заказы молча: блокируем их, чтобы вернуть резерв и записать события
+	var orders []struct {
+		ID     int64              `db:"id"`
+		Status entity.OrderStatus `db:"status"`
+	}
+	if err := tx.SelectContext(ctx, &orders, "SELECT id, status FROM orders WHERE user_id = $1 ORDER BY id FOR UPDATE", id); err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]int64, 0, len(orders))
+	var holding []int64
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+		if holdsStock(order.Status) {
+			holding = append(holding, order.ID)
+		}
+	}
+	if err := releaseOrdersStock(ctx, tx, holding); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	for _, orderID := range orderIDs {
+		payload := entity.OrderDeletedPayload{ID: orderID, UserID: id}
+		if err := addEvent(ctx, tx, entity.AggregateOrder, orderID, entity.EventOrderDeleted, payload); err != nil {
+			return nil, err
+		}
+	}
+	if err := addEvent(ctx, tx, entity.AggregateUser, id, entity.EventUserDeleted, entity.UserDeletedPayload{ID: id}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
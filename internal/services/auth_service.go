@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+type TokenManager interface {
+	NewAccessToken(userID int64, role string) (string, error)
+	NewRefreshToken() (string, error)
+	AccessTTL() time.Duration
+}
+
+type RefreshTokenStore interface {
+	Save(ctx context.Context, token string, userID int64, ttl time.Duration) error
+	Consume(ctx context.Context, token string) (int64, error)
+	Delete(ctx context.Context, token string) error
+}
+
+// CartMerger переносит анонимную корзину пользователю при входе
+type CartMerger interface {
+	MergeCart(ctx context.Context, cartID string, userID int64) error
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type AuthService struct {
+	repo          repositories.UserRepository
+	hasher        PasswordHasher
+	tokens        TokenManager
+	refreshTokens RefreshTokenStore
+	refreshTTL    time.Duration
+	carts         CartMerger
+}
+
+func NewAuthService(
+	repo repositories.UserRepository,
+	hasher PasswordHasher,
+	tokens TokenManager,
+	refreshTokens RefreshTokenStore,
+	refreshTTL time.Duration,
+	carts CartMerger,
+) *AuthService {
+	return &AuthService{
+		repo:          repo,
+		hasher:        hasher,
+		tokens:        tokens,
+		refreshTokens: refreshTokens,
+		refreshTTL:    refreshTTL,
+		carts:         carts,
+	}
+}
+
+// dummyPasswordHash bcrypt хеш случайной строки с той же стоимостью, что у BcryptHasher.
+// Сверяемся с ним, когда настоящего хеша нет, чтобы по времени ответа нельзя было понять, есть ли такой email
+const dummyPasswordHash = "$2a$10$5DSgonX9MaF4empW44mzG.nSb.Qqi.dDLlH5HpvtJKQ.eqtyCGYle"
+
+// Login проверяет пароль и выдаёт пару токенов. cartID необязательный:
+// если при входе есть анонимная корзина, она переносится пользователю
+func (s *AuthService) Login(ctx context.Context, email, password, cartID string) (*TokenPair, error) {
+	// Идём мимо кеша: в кеше нет password_hash
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			_ = s.hasher.Compare(dummyPasswordHash, password)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if user.PasswordHash == "" {
+		_ = s.hasher.Compare(dummyPasswordHash, password)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.hasher.Compare(user.PasswordHash, password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	pair, err := s.issueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Вход не должен падать из-за корзины, в худшем случае она останется анонимной
+	if cartID != "" && s.carts != nil {
+		if err := s.carts.MergeCart(ctx, cartID, user.ID); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "merge anonymous cart", slog.Any("error", err))
+		}
+	}
+
+	return pair, nil
+}
+
+// Refresh меняет refresh токен на новую пару, старый токен при этом сгорает
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	userID, err := s.refreshTokens.Consume(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	// Пользователя могли удалить, пока токен был жив, а роль могла поменяться
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	return s.refreshTokens.Delete(ctx, refreshToken)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *entity.User) (*TokenPair, error) {
+	access, err := s.tokens.NewAccessToken(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := s.tokens.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pair := &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.tokens.AccessTTL().Seconds()),
+	}
+
+	// Без хранилища refresh токенов вход всё равно работает: клиент получит только access токен
+	// и после его истечения войдёт заново
+	if err := s.refreshTokens.Save(ctx, refresh, user.ID, s.refreshTTL); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "save refresh token, issuing access token only", slog.Any("error", err))
+		pair.RefreshToken = ""
+	}
+
+	return pair, nil
+}
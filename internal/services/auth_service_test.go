@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type MockTokenManager struct{ mock.Mock }
+
+func (m *MockTokenManager) NewAccessToken(userID int64, role string) (string, error) {
+	args := m.Called(userID, role)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTokenManager) NewRefreshToken() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTokenManager) AccessTTL() time.Duration { return 15 * time.Minute }
+
+type MockRefreshStore struct{ mock.Mock }
+
+func (m *MockRefreshStore) Save(ctx context.Context, token string, userID int64, ttl time.Duration) error {
+	return m.Called(ctx, token, userID, ttl).Error(0)
+}
+
+func (m *MockRefreshStore) Consume(ctx context.Context, token string) (int64, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRefreshStore) Delete(ctx context.Context, token string) error {
+	return m.Called(ctx, token).Error(0)
+}
+
+func TestAuthService_Login(t *testing.T) {
+	ctx := context.Background()
+	refreshTTL := time.Hour
+
+	t.Run("success", func(t *testing.T) {
+		repo, hasher := new(MockUserRepo), new(MockHasher)
+		tokens, store := new(MockTokenManager), new(MockRefreshStore)
+		service := NewAuthService(repo, hasher, tokens, store, refreshTTL, nil)
+
+		user := &entity.User{ID: 7, Email: "max@test.com", PasswordHash: "hashed", Role: "customer"}
+		repo.On("GetByEmail", ctx, "max@test.com").Return(user, nil)
+		hasher.On("Compare", "hashed", "123456").Return(nil)
+		tokens.On("NewAccessToken", int64(7), "customer").Return("access", nil)
+		tokens.On("NewRefreshToken").Return("refresh", nil)
+		store.On("Save", ctx, "refresh", int64(7), refreshTTL).Return(nil)
+
+		pair, err := service.Login(ctx, "max@test.com", "123456", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "access", pair.AccessToken)
+		assert.Equal(t, "refresh", pair.RefreshToken)
+		assert.Equal(t, int64(900), pair.ExpiresIn)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		repo, hasher := new(MockUserRepo), new(MockHasher)
+		service := NewAuthService(repo, hasher, new(MockTokenManager), new(MockRefreshStore), refreshTTL, nil)
+
+		repo.On("GetByEmail", ctx, "nobody@test.com").Return(nil, repositories.ErrUserNotFound)
+		// Время ответа то же, что у существующего email с неверным паролем
+		hasher.On("Compare", dummyPasswordHash, "123456").Return(errors.New("mismatch"))
+
+		pair, err := service.Login(ctx, "nobody@test.com", "123456", "")
+
+		assert.Nil(t, pair)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		hasher.AssertExpectations(t)
+
+		cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+		assert.NoError(t, err)
+		assert.Equal(t, bcrypt.DefaultCost, cost)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		repo, hasher, tokens := new(MockUserRepo), new(MockHasher), new(MockTokenManager)
+		service := NewAuthService(repo, hasher, tokens, new(MockRefreshStore), refreshTTL, nil)
+
+		user := &entity.User{ID: 7, Email: "max@test.com", PasswordHash: "hashed", Role: "customer"}
+		repo.On("GetByEmail", ctx, "max@test.com").Return(user, nil)
+		hasher.On("Compare", "hashed", "wrong").Return(errors.New("mismatch"))
+
+		pair, err := service.Login(ctx, "max@test.com", "wrong", "")
+
+		assert.Nil(t, pair)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		tokens.AssertNotCalled(t, "NewAccessToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("refresh store down still logs in", func(t *testing.T) {
+		repo, hasher := new(MockUserRepo), new(MockHasher)
+		tokens, store := new(MockTokenManager), new(MockRefreshStore)
+		service := NewAuthService(repo, hasher, tokens, store, refreshTTL, nil)
+
+		user := &entity.User{ID: 7, Email: "max@test.com", PasswordHash: "hashed", Role: "customer"}
+		repo.On("GetByEmail", ctx, "max@test.com").Return(user, nil)
+		hasher.On("Compare", "hashed", "123456").Return(nil)
+		tokens.On("NewAccessToken", int64(7), "customer").Return("access", nil)
+		tokens.On("NewRefreshToken").Return("refresh", nil)
+		store.On("Save", ctx, "refresh", int64(7), refreshTTL).Return(errors.New("connection refused"))
+
+		pair, err := service.Login(ctx, "max@test.com", "123456", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "access", pair.AccessToken)
+		assert.Empty(t, pair.RefreshToken)
+	})
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	refreshTTL := time.Hour
+
+	t.Run("rotates token", func(t *testing.T) {
+		repo, tokens, store := new(MockUserRepo), new(MockTokenManager), new(MockRefreshStore)
+		service := NewAuthService(repo, new(MockHasher), tokens, store, refreshTTL, nil)
+
+		store.On("Consume", ctx, "old").Return(int64(7), nil)
+		repo.On("GetByID", ctx, int64(7)).Return(&entity.User{ID: 7, Role: "admin"}, nil)
+		tokens.On("NewAccessToken", int64(7), "admin").Return("access", nil)
+		tokens.On("NewRefreshToken").Return("new", nil)
+		store.On("Save", ctx, "new", int64(7), refreshTTL).Return(nil)
+
+		pair, err := service.Refresh(ctx, "old")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "new", pair.RefreshToken)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		store := new(MockRefreshStore)
+		service := NewAuthService(new(MockUserRepo), new(MockHasher), new(MockTokenManager), store, refreshTTL, nil)
+
+		store.On("Consume", ctx, "revoked").Return(int64(0), repositories.ErrRefreshTokenNotFound)
+
+		pair, err := service.Refresh(ctx, "revoked")
+
+		assert.Nil(t, pair)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
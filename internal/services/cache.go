@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	userCacheTTL  = 15 * time.Minute
+	orderCacheTTL = 15 * time.Minute
+	// notFoundTTL короткий, чтобы только что созданная запись не пряталась за промахом надолго
+	notFoundTTL = 30 * time.Second
+
+	ttlJitter = 0.1
+
+	// loadTimeout сколько общая загрузка может идти после того, как ушли все, кто её ждал
+	loadTimeout = 5 * time.Second
+)
+
+// jitter разбрасывает TTL на ±10%, чтобы записи, положенные одновременно, не истекали разом
+func jitter(ttl time.Duration) time.Duration {
+	spread := time.Duration(float64(ttl) * ttlJitter)
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl - spread + rand.N(2*spread+1)
+}
+
+// coalesce выполняет load один раз на все одновременные промахи по ключу, остальные ждут результат.
+// load получает контекст, отвязанный от отмены первого вызова: если его клиент отвалится,
+// остальные ждущие всё равно получат ответ. Каждый ждущий уходит по своему ctx
+func coalesce[T any](ctx context.Context, group *singleflight.Group, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	ch := group.DoChan(key, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return load(loadCtx)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+)
+
+// Ключи кеша строятся как <namespace>:<версия>:<сущность>:<индекс>:<значение>, чтобы индексы
+// разных сущностей не пересекались. Версию поднимаем при несовместимом изменении самих сущностей:
+// новый код не прочитает старые записи, они просто доживут свой TTL. Смена кодека версии не требует,
+// формат значения записан в его заголовке (см. internal/cache/codec)
+const cacheNamespace = "commerce:v1"
+
+func userIDKey(id int64) string {
+	return fmt.Sprintf("%s:user:id:%d", cacheNamespace, id)
+}
+
+func userEmailKey(email string) string {
+	return fmt.Sprintf("%s:user:email:%s", cacheNamespace, email)
+}
+
+// userKeys все ключи, под которыми может лежать пользователь
+func userKeys(user *entity.User) []string {
+	return []string{userIDKey(user.ID), userEmailKey(user.Email)}
+}
+
+func orderIDKey(id int64) string {
+	return fmt.Sprintf("%s:order:id:%d", cacheNamespace, id)
+}
+
+// Все страницы истории пользователя лежат под одним ключом, чтобы сбрасывать их разом
+func userOrdersKey(userID int64) string {
+	return fmt.Sprintf("%s:orders:user:%d", cacheNamespace, userID)
+}
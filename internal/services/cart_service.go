@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/logging"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+)
+
+var (
+	ErrCartEmpty       = errors.New("cart is empty")
+	ErrInvalidCartID   = errors.New("invalid cart id")
+	ErrInvalidQuantity = errors.New("invalid quantity")
+)
+
+// MaxCartQuantity ограничение на одну позицию, чтобы hash не раздувался мусорными значениями
+const MaxCartQuantity = 1000
+
+// restoreTimeout сколько даём на возврат товаров в корзину после неудачного checkout
+const restoreTimeout = 5 * time.Second
+
+type CartStore interface {
+	Items(ctx context.Context, key string) (map[int64]int, error)
+	Add(ctx context.Context, key string, productID int64, quantity, limit int, ttl time.Duration) error
+	SetQuantity(ctx context.Context, key string, productID int64, quantity int, ttl time.Duration) error
+	Remove(ctx context.Context, key string, productID int64) error
+	Claim(ctx context.Context, key string) (map[int64]int, error)
+	Restore(ctx context.Context, key string, items map[int64]int, limit int, ttl time.Duration) error
+}
+
+type OrderCreator interface {
+	CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
+}
+
+type CartService struct {
+	store    CartStore
+	products repositories.ProductRepository
+	orders   OrderCreator
+	ttl      time.Duration
+}
+
+func NewCartService(store CartStore, products repositories.ProductRepository, orders OrderCreator, ttl time.Duration) *CartService {
+	return &CartService{
+		store:    store,
+		products: products,
+		orders:   orders,
+		ttl:      ttl,
+	}
+}
+
+// NewCartID выдаёт id анонимной корзины, его нельзя угадать, поэтому он сам по себе служит доступом
+func NewCartID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// cartKey у вошедшего пользователя корзина привязана к нему, у анонима к id из заголовка
+func cartKey(ctx context.Context, cartID string) (string, error) {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return userCartKey(identity.UserID), nil
+	}
+	return anonCartKey(cartID)
+}
+
+func userCartKey(userID int64) string {
+	return fmt.Sprintf("cart:user:%d", userID)
+}
+
+func anonCartKey(cartID string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cartID)
+	if err != nil || len(raw) != 16 {
+		return "", ErrInvalidCartID
+	}
+	return "cart:anon:" + cartID, nil
+}
+
+func (s *CartService) GetCart(ctx context.Context, cartID string) (*entity.Cart, error) {
+	key, err := cartKey(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.store.Items(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.describe(ctx, items)
+}
+
+func (s *CartService) AddItem(ctx context.Context, cartID string, productID int64, quantity int) (*entity.Cart, error) {
+	if quantity <= 0 || quantity > MaxCartQuantity {
+		return nil, ErrInvalidQuantity
+	}
+	if err := s.checkProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	key, err := cartKey(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Add(ctx, key, productID, quantity, MaxCartQuantity, s.ttl); err != nil {
+		if errors.Is(err, repositories.ErrCartQuantityLimit) {
+			return nil, fmt.Errorf("%w: at most %d per item", ErrInvalidQuantity, MaxCartQuantity)
+		}
+		return nil, err
+	}
+
+	return s.GetCart(ctx, cartID)
+}
+
+// UpdateItem выставляет количество, ноль убирает товар из корзины
+func (s *CartService) UpdateItem(ctx context.Context, cartID string, productID int64, quantity int) (*entity.Cart, error) {
+	if quantity < 0 || quantity > MaxCartQuantity {
+		return nil, ErrInvalidQuantity
+	}
+	if quantity == 0 {
+		return s.RemoveItem(ctx, cartID, productID)
+	}
+	if err := s.checkProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	key, err := cartKey(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.SetQuantity(ctx, key, productID, quantity, s.ttl); err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(ctx, cartID)
+}
+
+func (s *CartService) RemoveItem(ctx context.Context, cartID string, productID int64) (*entity.Cart, error) {
+	key, err := cartKey(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Remove(ctx, key, productID); err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(ctx, cartID)
+}
+
+// Checkout забирает корзину и оформляет её заказом. Если заказ не создался
+// (нет товара на складе, товар сняли с продажи), товары возвращаются в корзину
+func (s *CartService) Checkout(ctx context.Context) (*entity.Order, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, policy.ErrUnauthenticated
+	}
+	key := userCartKey(identity.UserID)
+
+	items, err := s.store.Claim(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	order := &entity.Order{UserID: identity.UserID}
+	for _, productID := range sortedProductIDs(items) {
+		order.Items = append(order.Items, entity.OrderItem{ProductID: productID, Quantity: items[productID]})
+	}
+
+	created, err := s.orders.CreateOrder(ctx, order)
+	if err != nil {
+		// Клиент мог уже отключиться, но забранная корзина иначе пропадёт насовсем
+		restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+		defer cancel()
+		if restoreErr := s.store.Restore(restoreCtx, key, items, MaxCartQuantity, s.ttl); restoreErr != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "restore cart after failed checkout", slog.Any("error", restoreErr))
+		}
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// MergeCart переносит анонимную корзину в корзину пользователя после входа
+func (s *CartService) MergeCart(ctx context.Context, cartID string, userID int64) error {
+	from, err := anonCartKey(cartID)
+	if err != nil {
+		return err
+	}
+
+	items, err := s.store.Claim(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Restore(ctx, userCartKey(userID), items, MaxCartQuantity, s.ttl)
+}
+
+func (s *CartService) checkProduct(ctx context.Context, productID int64) error {
+	product, err := s.products.GetByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrProductNotFound) {
+			return fmt.Errorf("%w: %d", ErrProductUnavailable, productID)
+		}
+		return err
+	}
+	if !product.Active {
+		return fmt.Errorf("%w: %d", ErrProductUnavailable, productID)
+	}
+	return nil
+}
+
+// describe подтягивает названия и текущие цены, недоступные товары в сумму не входят
+func (s *CartService) describe(ctx context.Context, items map[int64]int) (*entity.Cart, error) {
+	cart := &entity.Cart{Items: []entity.CartItem{}}
+	if len(items) == 0 {
+		return cart, nil
+	}
+
+	ids := sortedProductIDs(items)
+	products, err := s.products.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[int64]entity.Product, len(products))
+	for _, product := range products {
+		catalog[product.ID] = product
+	}
+
+	for _, id := range ids {
+		item := entity.CartItem{ProductID: id, Quantity: items[id]}
+		if product, ok := catalog[id]; ok {
+			item.Name = product.Name
+			item.Price = product.Price
+			item.Available = product.Active
+		}
+		if item.Available {
+			cart.Total += item.Price * int64(item.Quantity)
+		}
+		cart.Items = append(cart.Items, item)
+	}
+
+	return cart, nil
+}
+
+func sortedProductIDs(items map[int64]int) []int64 {
+	ids := make([]int64, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCartStore struct{ mock.Mock }
+
+func (m *MockCartStore) Items(ctx context.Context, key string) (map[int64]int, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(map[int64]int), args.Error(1)
+}
+
+func (m *MockCartStore) Add(ctx context.Context, key string, productID int64, quantity, limit int, ttl time.Duration) error {
+	return m.Called(ctx, key, productID, quantity, limit, ttl).Error(0)
+}
+
+func (m *MockCartStore) SetQuantity(ctx context.Context, key string, productID int64, quantity int, ttl time.Duration) error {
+	return m.Called(ctx, key, productID, quantity, ttl).Error(0)
+}
+
+func (m *MockCartStore) Remove(ctx context.Context, key string, productID int64) error {
+	return m.Called(ctx, key, productID).Error(0)
+}
+
+func (m *MockCartStore) Claim(ctx context.Context, key string) (map[int64]int, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(map[int64]int), args.Error(1)
+}
+
+func (m *MockCartStore) Restore(ctx context.Context, key string, items map[int64]int, limit int, ttl time.Duration) error {
+	return m.Called(ctx, key, items, limit, ttl).Error(0)
+}
+
+type MockOrderCreator struct{ mock.Mock }
+
+func (m *MockOrderCreator) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	args := m.Called(ctx, order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Order), args.Error(1)
+}
+
+func TestCartService(t *testing.T) {
+	ttl := time.Hour
+	cartID, _ := NewCartID()
+
+	t.Run("anonymous adds item and sees catalog prices", func(t *testing.T) {
+		store, products := new(MockCartStore), new(MockProductRepo)
+		service := NewCartService(store, products, new(MockOrderCreator), ttl)
+		key := "cart:anon:" + cartID
+
+		products.On("GetByID", mock.Anything, int64(10)).Return(&entity.Product{ID: 10, Active: true}, nil)
+		store.On("Add", mock.Anything, key, int64(10), 2, MaxCartQuantity, ttl).Return(nil)
+		store.On("Items", mock.Anything, key).Return(map[int64]int{10: 2, 11: 1}, nil)
+		products.On("GetByIDs", mock.Anything, []int64{10, 11}).Return([]entity.Product{
+			{ID: 10, Name: "book", Price: 100, Active: true},
+			{ID: 11, Name: "old", Price: 50, Active: false},
+		}, nil)
+
+		cart, err := service.AddItem(context.Background(), cartID, 10, 2)
+
+		assert.NoError(t, err)
+		assert.Len(t, cart.Items, 2)
+		assert.Equal(t, int64(200), cart.Total)
+		assert.False(t, cart.Items[1].Available)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("rejects forged cart id", func(t *testing.T) {
+		service := NewCartService(new(MockCartStore), new(MockProductRepo), new(MockOrderCreator), ttl)
+
+		_, err := service.GetCart(context.Background(), "cart:user:1")
+
+		assert.ErrorIs(t, err, ErrInvalidCartID)
+	})
+
+	t.Run("rejects inactive product", func(t *testing.T) {
+		store, products := new(MockCartStore), new(MockProductRepo)
+		service := NewCartService(store, products, new(MockOrderCreator), ttl)
+
+		products.On("GetByID", mock.Anything, int64(12)).Return(nil, repositories.ErrProductNotFound)
+
+		_, err := service.AddItem(withIdentity(1, auth.RoleCustomer), "", 12, 1)
+
+		assert.ErrorIs(t, err, ErrProductUnavailable)
+		store.AssertNotCalled(t, "Add", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("adding past the per item limit is invalid quantity", func(t *testing.T) {
+		store, products := new(MockCartStore), new(MockProductRepo)
+		service := NewCartService(store, products, new(MockOrderCreator), ttl)
+
+		products.On("GetByID", mock.Anything, int64(10)).Return(&entity.Product{ID: 10, Active: true}, nil)
+		store.On("Add", mock.Anything, "cart:user:1", int64(10), 600, MaxCartQuantity, ttl).Return(repositories.ErrCartQuantityLimit)
+
+		_, err := service.AddItem(withIdentity(1, auth.RoleCustomer), "", 10, 600)
+
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		store.AssertNotCalled(t, "Items", mock.Anything, mock.Anything)
+	})
+
+	t.Run("checkout creates order from claimed cart", func(t *testing.T) {
+		store, orders := new(MockCartStore), new(MockOrderCreator)
+		service := NewCartService(store, new(MockProductRepo), orders, ttl)
+		ctx := withIdentity(1, auth.RoleCustomer)
+
+		store.On("Claim", ctx, "cart:user:1").Return(map[int64]int{11: 1, 10: 2}, nil)
+		orders.On("CreateOrder", ctx, mock.MatchedBy(func(o *entity.Order) bool {
+			return o.UserID == 1 && len(o.Items) == 2 &&
+				o.Items[0].ProductID == 10 && o.Items[0].Quantity == 2 &&
+				o.Items[1].ProductID == 11 && o.Items[1].Quantity == 1
+		})).Return(&entity.Order{ID: 5, UserID: 1}, nil)
+
+		order, err := service.Checkout(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), order.ID)
+		store.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed checkout restores cart", func(t *testing.T) {
+		store, orders := new(MockCartStore), new(MockOrderCreator)
+		service := NewCartService(store, new(MockProductRepo), orders, ttl)
+		ctx := withIdentity(1, auth.RoleCustomer)
+		items := map[int64]int{10: 2}
+
+		store.On("Claim", ctx, "cart:user:1").Return(items, nil)
+		orders.On("CreateOrder", ctx, mock.Anything).Return(nil, repositories.ErrInsufficientStock)
+		store.On("Restore", mock.Anything, "cart:user:1", items, MaxCartQuantity, ttl).Return(nil)
+
+		_, err := service.Checkout(ctx)
+
+		assert.ErrorIs(t, err, repositories.ErrInsufficientStock)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("cart is restored after client disconnects", func(t *testing.T) {
+		store, orders := new(MockCartStore), new(MockOrderCreator)
+		service := NewCartService(store, new(MockProductRepo), orders, ttl)
+		ctx, cancel := context.WithCancel(withIdentity(1, auth.RoleCustomer))
+		items := map[int64]int{10: 2}
+
+		store.On("Claim", ctx, "cart:user:1").Return(items, nil)
+		orders.On("CreateOrder", ctx, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(nil, context.Canceled)
+		store.On("Restore", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), "cart:user:1", items, MaxCartQuantity, ttl).Return(nil)
+
+		_, err := service.Checkout(ctx)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("checkout requires login and non empty cart", func(t *testing.T) {
+		store := new(MockCartStore)
+		service := NewCartService(store, new(MockProductRepo), new(MockOrderCreator), ttl)
+
+		_, err := service.Checkout(context.Background())
+		assert.ErrorIs(t, err, policy.ErrUnauthenticated)
+
+		ctx := withIdentity(1, auth.RoleCustomer)
+		store.On("Claim", ctx, "cart:user:1").Return(map[int64]int{}, nil)
+		_, err = service.Checkout(ctx)
+		assert.ErrorIs(t, err, ErrCartEmpty)
+	})
+
+	t.Run("merge moves anonymous cart to user", func(t *testing.T) {
+		store := new(MockCartStore)
+		service := NewCartService(store, new(MockProductRepo), new(MockOrderCreator), ttl)
+		items := map[int64]int{10: 1}
+
+		store.On("Claim", mock.Anything, "cart:anon:"+cartID).Return(items, nil)
+		store.On("Restore", mock.Anything, "cart:user:7", items, MaxCartQuantity, ttl).Return(nil)
+
+		assert.NoError(t, service.MergeCart(context.Background(), cartID, 7))
+		store.AssertExpectations(t)
+	})
+}
@@ -3,10 +3,15 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/auth"
 	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -26,20 +31,31 @@ func (m *MockOrderRepo) GetOrderByID(ctx context.Context, id int64) (*entity.Ord
 	return args.Get(0).(*entity.Order), args.Error(1)
 }
 
-func (m *MockOrderRepo) GetOrderByUserID(ctx context.Context, user_id int64) (*entity.Order, error) {
-	args := m.Called(ctx, user_id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*entity.Order), args.Error(1)
+func (m *MockOrderRepo) ListOrdersByUserID(ctx context.Context, userID int64, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	args := m.Called(ctx, userID, after, limit)
+	return args.Get(0).([]entity.Order), args.Error(1)
+}
+
+func (m *MockOrderRepo) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.Order), args.Error(1)
 }
 
 func (m *MockOrderRepo) UpdateOrder(ctx context.Context, order *entity.Order) error {
 	return m.Called(ctx, order).Error(0)
 }
 
-func (m *MockOrderRepo) DeleteOrderByID(ctx context.Context, id int64) error {
-	return m.Called(ctx, id).Error(0)
+func (m *MockOrderRepo) DeleteOrderByID(ctx context.Context, id int64, allowed []entity.OrderStatus) error {
+	return m.Called(ctx, id, allowed).Error(0)
+}
+
+func (m *MockOrderRepo) TransitionStatus(ctx context.Context, id int64, from, to entity.OrderStatus, changedBy int64) error {
+	return m.Called(ctx, id, from, to, changedBy).Error(0)
+}
+
+func (m *MockOrderRepo) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusChange, error) {
+	args := m.Called(ctx, orderID)
+	return args.Get(0).([]entity.OrderStatusChange), args.Error(1)
 }
 
 type MockOrderCache struct{ mock.Mock }
@@ -57,51 +73,112 @@ func (m *MockOrderCache) Set(ctx context.Context, key string, order *entity.Orde
 	return args.Error(0)
 }
 
+func (m *MockOrderCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return m.Called(ctx, key, ttl).Error(0)
+}
+
+func (m *MockOrderCache) GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error) {
+	args := m.Called(ctx, key, field)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.OrderPage), args.Error(1)
+}
+
+func (m *MockOrderCache) SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error {
+	return m.Called(ctx, key, field, page, ttl).Error(0)
+}
+
 func TestOrderService_CreateOrder(t *testing.T) {
 	repo := new(MockOrderRepo)
+	products := new(MockProductRepo)
 	cache := new(MockOrderCache)
-	service := NewOrderService(repo, cache)
+	service := NewOrderService(repo, products, cache, testPolicy())
 
 	t.Run("should calculate total correctly", func(t *testing.T) {
+		// Цена от клиента игнорируется, считается по каталогу
 		order := &entity.Order{
+			UserID: 1,
 			Items: []entity.OrderItem{
-				{Price: 100, Quantity: 2},
-				{Price: 50, Quantity: 3},
+				{ProductID: 10, Price: 1, Quantity: 2},
+				{ProductID: 11, Quantity: 3},
 			},
 		}
 
+		products.On("GetByIDs", mock.Anything, []int64{10, 11}).Return([]entity.Product{
+			{ID: 10, Name: "book", Price: 100, Active: true},
+			{ID: 11, Name: "pen", Price: 50, Active: true},
+		}, nil).Once()
+
 		repo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(o *entity.Order) bool {
 			return o.Total == 350
 		})).Return(order, nil)
+		cache.On("Set", mock.Anything, orderIDKey(0), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+		cache.On("Set", mock.Anything, userOrdersKey(1), (*entity.Order)(nil), time.Duration(0)).Return(nil)
 
-		res, err := service.CreateOrder(context.Background(), order)
+		res, err := service.CreateOrder(withIdentity(1, auth.RoleCustomer), order)
 
 		assert.NoError(t, err)
 		assert.Equal(t, int64(350), res.Total)
+		assert.Equal(t, "book", res.Items[0].Name)
+		assert.Equal(t, int64(100), res.Items[0].Price)
 		repo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("rejects inactive product", func(t *testing.T) {
+		order := &entity.Order{UserID: 1, Items: []entity.OrderItem{{ProductID: 12, Quantity: 1}}}
+
+		products.On("GetByIDs", mock.Anything, []int64{12}).Return([]entity.Product{
+			{ID: 12, Name: "old", Price: 10, Active: false},
+		}, nil).Once()
+
+		res, err := service.CreateOrder(withIdentity(1, auth.RoleCustomer), order)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, ErrProductUnavailable)
+	})
+
+	t.Run("rejects non positive quantity", func(t *testing.T) {
+		// gRPC и корзина не проходят валидацию gin, поэтому проверяет сервис
+		order := &entity.Order{UserID: 1, Items: []entity.OrderItem{{ProductID: 10, Quantity: 0}}}
+
+		res, err := service.CreateOrder(withIdentity(1, auth.RoleCustomer), order)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+
+	t.Run("rejects item without product", func(t *testing.T) {
+		order := &entity.Order{UserID: 1, Items: []entity.OrderItem{{Name: "free", Price: 0, Quantity: 1}}}
+
+		res, err := service.CreateOrder(withIdentity(1, auth.RoleCustomer), order)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, ErrProductUnavailable)
 	})
 
 	t.Run("should return if no items", func(t *testing.T) {
 		order := &entity.Order{Items: []entity.OrderItem{}}
 
-		res, err := service.CreateOrder(context.Background(), order)
+		res, err := service.CreateOrder(withIdentity(1, auth.RoleCustomer), order)
 
 		assert.Error(t, err)
 		assert.Nil(t, res)
-		assert.Equal(t, "order must be have at least one item", err.Error())
+		assert.Equal(t, "order must have at least one item", err.Error())
 	})
 }
 
 func TestOrderService_GetOrderByID(t *testing.T) {
 	repo := new(MockOrderRepo)
 	cache := new(MockOrderCache)
-	service := NewOrderService(repo, cache)
-	ctx := context.Background()
+	service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+	ctx := withIdentity(1, auth.RoleCustomer)
 	orderID := int64(1)
-	cacheKey := "order:1"
+	cacheKey := orderIDKey(1)
 
 	t.Run("cache hit - should not call repo", func(t *testing.T) {
-		expectedOrder := &entity.Order{ID: orderID, Total: 500}
+		expectedOrder := &entity.Order{ID: orderID, UserID: 1, Total: 500}
 
 		// Настраиваем кеш: он должен вернуть заказ
 		cache.On("Get", ctx, cacheKey).Return(expectedOrder, nil)
@@ -118,14 +195,14 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 		// Очищаем ожидания от предыдущего теста
 		cache.ExpectedCalls = nil
 
-		expectedOrder := &entity.Order{ID: orderID, Total: 500}
+		expectedOrder := &entity.Order{ID: orderID, UserID: 1, Total: 500}
 
 		// 1. Кеш возвращает ошибку или nil
 		cache.On("Get", ctx, cacheKey).Return(nil, errors.New("not found"))
 		// 2. Репозиторий возвращает данные
-		repo.On("GetOrderByID", ctx, orderID).Return(expectedOrder, nil)
+		repo.On("GetOrderByID", mock.Anything, orderID).Return(expectedOrder, nil)
 		// 3. Сервис должен сохранить данные в кеш
-		cache.On("Set", ctx, cacheKey, expectedOrder, mock.Anything).Return(nil)
+		cache.On("Set", mock.Anything, cacheKey, expectedOrder, mock.Anything).Return(nil)
 
 		res, err := service.GetOrderByID(ctx, orderID)
 
@@ -135,3 +212,347 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 		cache.AssertExpectations(t)
 	})
 }
+
+func TestOrderService_Policy(t *testing.T) {
+	order := &entity.Order{
+		ID:     5,
+		UserID: 1,
+		Status: entity.OrderStatusPending,
+		Total:  500,
+		Items:  []entity.OrderItem{{ID: 1, OrderID: 5, Name: "legacy", Quantity: 5, Price: 100}},
+	}
+
+	newService := func() (*OrderService, *MockOrderRepo, *MockOrderCache) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		cache.On("Get", mock.Anything, orderIDKey(5)).Return(order, nil)
+		return NewOrderService(repo, new(MockProductRepo), cache, testPolicy()), repo, cache
+	}
+
+	t.Run("customer cannot read foreign order", func(t *testing.T) {
+		service, _, _ := newService()
+
+		res, err := service.GetOrderByID(withIdentity(2, auth.RoleCustomer), 5)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+	})
+
+	t.Run("support reads but cannot delete", func(t *testing.T) {
+		service, repo, _ := newService()
+		ctx := withIdentity(2, auth.RoleSupport)
+
+		res, err := service.GetOrderByID(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, order, res)
+
+		err = service.DeleteOrder(ctx, 5)
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "DeleteOrderByID", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin changes any order", func(t *testing.T) {
+		service, repo, cache := newService()
+		ctx := withIdentity(2, auth.RoleAdmin)
+		// Позиция без product_id из старого заказа сохраняет записанную цену
+		update := &entity.Order{ID: 5, Items: []entity.OrderItem{{ID: 1, Price: 10, Quantity: 1}}}
+
+		repo.On("UpdateOrder", ctx, update).Return(nil)
+		cache.On("Set", ctx, orderIDKey(5), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+		cache.On("Set", ctx, userOrdersKey(1), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+
+		assert.NoError(t, service.UpdateOrder(ctx, update))
+		assert.Equal(t, int64(1), update.UserID)
+		assert.Equal(t, int64(100), update.Total)
+		assert.Equal(t, "legacy", update.Items[0].Name)
+		repo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+		// Сохранённый заказ в кеш не кладём, только сбрасываем
+		cache.AssertNotCalled(t, "Set", ctx, orderIDKey(5), update, mock.Anything)
+	})
+
+	t.Run("paid order cannot be edited", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		paid := &entity.Order{ID: 6, UserID: 1, Status: entity.OrderStatusPaid}
+		cache.On("Get", mock.Anything, orderIDKey(6)).Return(paid, nil)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		err := service.UpdateOrder(withIdentity(1, auth.RoleCustomer), &entity.Order{ID: 6})
+
+		assert.ErrorIs(t, err, ErrOrderNotEditable)
+		repo.AssertNotCalled(t, "UpdateOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("customer cannot create order for another user", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		_, err := service.CreateOrder(withIdentity(2, auth.RoleCustomer), &entity.Order{UserID: 1})
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOrderService_GetOrdersByUserID(t *testing.T) {
+	ctx := withIdentity(1, auth.RoleCustomer)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("builds next cursor from the last order", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		orders := []entity.Order{
+			{ID: 3, UserID: 1, CreatedAt: base.Add(2 * time.Minute)},
+			{ID: 2, UserID: 1, CreatedAt: base.Add(time.Minute)},
+			{ID: 1, UserID: 1, CreatedAt: base},
+		}
+		cache.On("GetPage", ctx, userOrdersKey(1), ":2").Return(nil, errors.New("miss"))
+		repo.On("ListOrdersByUserID", ctx, int64(1), (*entity.OrderCursor)(nil), 3).Return(orders, nil)
+		cache.On("SetPage", ctx, userOrdersKey(1), ":2", mock.Anything, mock.Anything).Return(nil)
+
+		page, err := service.GetOrdersByUserID(ctx, 1, "", 2)
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Orders, 2)
+
+		next, err := entity.DecodeOrderCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), next.ID)
+		assert.True(t, next.CreatedAt.Equal(base.Add(time.Minute)))
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		after := entity.OrderCursor{CreatedAt: base, ID: 10}
+		cursor := after.Encode()
+		cache.On("GetPage", ctx, userOrdersKey(1), cursor+":20").Return(nil, errors.New("miss"))
+		repo.On("ListOrdersByUserID", ctx, int64(1), &after, 21).Return([]entity.Order{{ID: 9, UserID: 1}}, nil)
+		cache.On("SetPage", ctx, userOrdersKey(1), cursor+":20", mock.Anything, mock.Anything).Return(nil)
+
+		page, err := service.GetOrdersByUserID(ctx, 1, cursor, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Orders, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		service := NewOrderService(new(MockOrderRepo), new(MockProductRepo), new(MockOrderCache), testPolicy())
+
+		_, err := service.GetOrdersByUserID(ctx, 1, "not a cursor", 10)
+
+		assert.ErrorIs(t, err, entity.ErrInvalidCursor)
+	})
+}
+
+func TestOrderService_ListOrders(t *testing.T) {
+	t.Run("customer needs own user_id filter", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+
+		_, err := service.ListOrders(withIdentity(1, auth.RoleCustomer), entity.OrderFilter{})
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "ListOrders", mock.Anything, mock.Anything)
+	})
+
+	t.Run("support lists everything", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+		ctx := withIdentity(2, auth.RoleSupport)
+
+		repo.On("ListOrders", ctx, mock.MatchedBy(func(f entity.OrderFilter) bool {
+			return f.SortBy == entity.OrderSortTotal && f.Limit == 3
+		})).Return([]entity.Order{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+
+		page, err := service.ListOrders(ctx, entity.OrderFilter{SortBy: entity.OrderSortTotal, Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Orders, 2)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+
+	t.Run("rejects unknown sort", func(t *testing.T) {
+		service := NewOrderService(new(MockOrderRepo), new(MockProductRepo), new(MockOrderCache), testPolicy())
+
+		_, err := service.ListOrders(withIdentity(2, auth.RoleSupport), entity.OrderFilter{SortBy: "name; DROP TABLE orders"})
+
+		assert.ErrorIs(t, err, entity.ErrInvalidFilter)
+	})
+}
+
+func TestOrderService_DeleteOrder_Invalidates(t *testing.T) {
+	repo, cache := new(MockOrderRepo), new(MockOrderCache)
+	service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+	ctx := withIdentity(1, auth.RoleAdmin)
+
+	cache.On("Get", ctx, orderIDKey(7)).Return(&entity.Order{ID: 7, UserID: 3}, nil)
+	repo.On("DeleteOrderByID", ctx, int64(7), []entity.OrderStatus(nil)).Return(nil)
+	cache.On("Set", ctx, orderIDKey(7), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+	cache.On("Set", ctx, userOrdersKey(3), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+
+	assert.NoError(t, service.DeleteOrder(ctx, 7))
+	repo.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
+
+func TestOrderService_DeleteOrder_OwnerOnlyUnpaid(t *testing.T) {
+	ctx := withIdentity(3, auth.RoleCustomer)
+
+	t.Run("owner deletes pending order", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		cache.On("Get", ctx, orderIDKey(7)).Return(&entity.Order{ID: 7, UserID: 3, Status: entity.OrderStatusPending}, nil)
+		repo.On("DeleteOrderByID", ctx, int64(7), ownerDeletableStatuses).Return(nil)
+		cache.On("Set", ctx, mock.Anything, (*entity.Order)(nil), time.Duration(0)).Return(nil)
+
+		assert.NoError(t, service.DeleteOrder(ctx, 7))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("owner cannot delete paid order", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		cache.On("Get", ctx, orderIDKey(7)).Return(&entity.Order{ID: 7, UserID: 3, Status: entity.OrderStatusPaid}, nil)
+
+		assert.ErrorIs(t, service.DeleteOrder(ctx, 7), policy.ErrForbidden)
+		repo.AssertNotCalled(t, "DeleteOrderByID", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCacheKeys_DoNotCollide(t *testing.T) {
+	keys := []string{orderIDKey(1), userOrdersKey(1), userIDKey(1), userEmailKey("1")}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		assert.True(t, strings.HasPrefix(key, cacheNamespace+":"), key)
+		assert.False(t, seen[key], key)
+		seen[key] = true
+	}
+}
+
+func TestOrderService_GetOrderByID_Coalesces(t *testing.T) {
+	repo, cache := new(MockOrderRepo), new(MockOrderCache)
+	service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+	ctx := withIdentity(1, auth.RoleAdmin)
+	order := &entity.Order{ID: 3, UserID: 1}
+
+	release := make(chan struct{})
+	cache.On("Get", ctx, orderIDKey(3)).Return(nil, errors.New("miss"))
+	cache.On("Set", mock.Anything, orderIDKey(3), order, mock.Anything).Return(nil)
+	repo.On("GetOrderByID", mock.Anything, int64(3)).Run(func(mock.Arguments) { <-release }).Return(order, nil)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]*entity.Order, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = service.GetOrderByID(ctx, 3)
+		}()
+	}
+	// Даём всем вызовам встать в ожидание первого запроса
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	repo.AssertNumberOfCalls(t, "GetOrderByID", 1)
+	for _, res := range results {
+		assert.Equal(t, order, res)
+	}
+}
+
+func TestOrderService_GetOrderByID_FirstCallerCancelled(t *testing.T) {
+	repo, cache := new(MockOrderRepo), new(MockOrderCache)
+	service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+	order := &entity.Order{ID: 3, UserID: 1}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	cache.On("Get", mock.Anything, orderIDKey(3)).Return(nil, errors.New("miss"))
+	cache.On("Set", mock.Anything, orderIDKey(3), order, mock.Anything).Return(nil)
+	repo.On("GetOrderByID", mock.Anything, int64(3)).Run(func(args mock.Arguments) {
+		close(started)
+		<-release
+		// Загрузка не видит отмену первого клиента
+		assert.NoError(t, args.Get(0).(context.Context).Err())
+	}).Return(order, nil)
+
+	firstCtx, cancelFirst := context.WithCancel(withIdentity(1, auth.RoleAdmin))
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := service.GetOrderByID(firstCtx, 3)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan *entity.Order, 1)
+	go func() {
+		res, _ := service.GetOrderByID(withIdentity(1, auth.RoleAdmin), 3)
+		second <- res
+	}()
+	// Второй вызов встаёт в ожидание, первый клиент отваливается
+	time.Sleep(20 * time.Millisecond)
+	cancelFirst()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	assert.Equal(t, order, <-second)
+	repo.AssertNumberOfCalls(t, "GetOrderByID", 1)
+}
+
+func TestOrderService_GetOrderByID_NegativeCache(t *testing.T) {
+	ctx := withIdentity(1, auth.RoleAdmin)
+
+	t.Run("not found is cached briefly", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		cache.On("Get", ctx, orderIDKey(404)).Return(nil, errors.New("miss"))
+		repo.On("GetOrderByID", mock.Anything, int64(404)).Return(nil, repositories.ErrOrderNotFound)
+		cache.On("SetNotFound", mock.Anything, orderIDKey(404), notFoundTTL).Return(nil)
+
+		_, err := service.GetOrderByID(ctx, 404)
+
+		assert.ErrorIs(t, err, repositories.ErrOrderNotFound)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("cached not found skips database", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		cache.On("Get", ctx, orderIDKey(404)).Return(nil, repositories.ErrOrderNotFound)
+
+		_, err := service.GetOrderByID(ctx, 404)
+
+		assert.ErrorIs(t, err, repositories.ErrOrderNotFound)
+		repo.AssertNotCalled(t, "GetOrderByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("database errors are not cached", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+
+		cache.On("Get", ctx, orderIDKey(5)).Return(nil, errors.New("miss"))
+		repo.On("GetOrderByID", mock.Anything, int64(5)).Return(nil, errors.New("connection reset"))
+
+		_, err := service.GetOrderByID(ctx, 5)
+
+		assert.Error(t, err)
+		cache.AssertNotCalled(t, "SetNotFound", mock.Anything, mock.Anything, mock.Anything)
+		cache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		ttl := jitter(10 * time.Minute)
+		assert.GreaterOrEqual(t, ttl, 9*time.Minute)
+		assert.LessOrEqual(t, ttl, 11*time.Minute)
+	}
+	assert.Equal(t, time.Duration(0), jitter(0))
+}
@@ -4,111 +4,374 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/auth"
 	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
 	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/tracing"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrOrderNil         = errors.New("order not must be a nil")
+	ErrOrderNoItems     = errors.New("order must have at least one item")
+	ErrOrderNotEditable = repositories.ErrOrderNotEditable
+	// ErrProductUnavailable позиция ссылается на товар, которого нет в каталоге или он снят с продажи
+	ErrProductUnavailable = errors.New("product is not available")
 )
 
-var ErrOrderNil = errors.New("order not must be a nil")
+const (
+	DefaultOrderPageSize = 20
+	MaxOrderPageSize     = 100
+)
 
+// Cache Get возвращает repositories.ErrOrderNotFound, если под ключом закеширован промах
 type Cache interface {
 	Get(ctx context.Context, key string) (*entity.Order, error)
 	Set(ctx context.Context, key string, order *entity.Order, ttl time.Duration) error
+	SetNotFound(ctx context.Context, key string, ttl time.Duration) error
+	GetPage(ctx context.Context, key, field string) (*entity.OrderPage, error)
+	SetPage(ctx context.Context, key, field string, page *entity.OrderPage, ttl time.Duration) error
 }
 
 type OrderService struct {
-	repo  repositories.OrderRepository
-	cache Cache
+	repo     repositories.OrderRepository
+	products repositories.ProductRepository
+	cache    Cache
+	policy   *policy.Policy
+	loads    singleflight.Group
 }
 
-func NewOrderService(repo repositories.OrderRepository, cache Cache) *OrderService {
+func NewOrderService(repo repositories.OrderRepository, products repositories.ProductRepository, cache Cache, policy *policy.Policy) *OrderService {
 	return &OrderService{
-		repo:  repo,
-		cache: cache,
+		repo:     repo,
+		products: products,
+		cache:    cache,
+		policy:   policy,
 	}
 }
 
-func (s *OrderService) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+func (s *OrderService) CreateOrder(ctx context.Context, order *entity.Order) (_ *entity.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderService.CreateOrder")
+	defer func() { tracing.End(span, err) }()
+
 	if order == nil {
 		return nil, ErrOrderNil
 	}
 
-	if len(order.Items) == 0 {
-		return nil, errors.New("order must have at least one item")
+	// Если владелец не указан, заказ оформляется на вызывающего
+	if identity, ok := auth.FromContext(ctx); ok && order.UserID == 0 {
+		order.UserID = identity.UserID
+	}
+	if err := s.policy.Authorize(ctx, policy.OrdersWrite, order.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := validateItems(order.Items); err != nil {
+		return nil, err
 	}
 
-	var total int64
-	for _, item := range order.Items {
-		total += item.Price * int64(item.Quantity)
+	if err := s.resolveItems(ctx, order.Items, nil); err != nil {
+		return nil, err
+	}
+	order.Total = orderTotal(order.Items)
+
+	created, err := s.repo.CreateOrder(ctx, order)
+	if err != nil {
+		return nil, err
 	}
-	order.Total = total
 
-	return s.repo.CreateOrder(ctx, order)
+	// Id мог быть закеширован как несуществующий, если его запрашивали заранее
+	s.invalidateOrder(ctx, created.ID, created.UserID)
+
+	return created, nil
 }
 
-func (s *OrderService) GetOrderByID(ctx context.Context, id int64) (*entity.Order, error) {
-	key := fmt.Sprintf("order:%d", id)
+func (s *OrderService) GetOrderByID(ctx context.Context, id int64) (_ *entity.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderService.GetOrderByID")
+	defer func() { tracing.End(span, err) }()
 
-	if order, err := s.cache.Get(ctx, key); err == nil && order != nil {
-		return order, nil
+	order, err := s.getOrder(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	order, err := s.repo.GetOrderByID(ctx, id)
+	if err := s.policy.Authorize(ctx, policy.OrdersRead, order.UserID); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetOrdersByUserID отдаёт историю заказов пользователя постранично, cursor берётся из next_cursor
+func (s *OrderService) GetOrdersByUserID(ctx context.Context, userID int64, cursor string, limit int) (_ *entity.OrderPage, err error) {
+	ctx, span := startSpan(ctx, "OrderService.GetOrdersByUserID")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Authorize(ctx, policy.OrdersRead, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultOrderPageSize
+	}
+	limit = min(limit, MaxOrderPageSize)
+
+	var after *entity.OrderCursor
+	if cursor != "" {
+		decoded, err := entity.DecodeOrderCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	key := userOrdersKey(userID)
+	field := fmt.Sprintf("%s:%d", cursor, limit)
+
+	if page, err := s.cache.GetPage(ctx, key, field); err == nil && page != nil {
+		return page, nil
+	}
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	orders, err := s.repo.ListOrdersByUserID(ctx, userID, after, limit+1)
 	if err != nil {
 		return nil, err
 	}
 
-	_ = s.cache.Set(ctx, key, order, 15*time.Minute)
+	page := &entity.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = entity.CursorAfter(last).Encode()
+	}
 
-	return order, nil
+	_ = s.cache.SetPage(ctx, key, field, page, jitter(orderCacheTTL))
+
+	return page, nil
 }
 
-func (s *OrderService) GetOrderbyUserID(ctx context.Context, user_id int64) (*entity.Order, error) {
-	key := fmt.Sprintf("order:%d", user_id)
+// ListOrders общий список заказов для поддержки, без фильтра по пользователю нужно право orders:read
+func (s *OrderService) ListOrders(ctx context.Context, filter entity.OrderFilter) (_ *entity.OrderPage, err error) {
+	ctx, span := startSpan(ctx, "OrderService.ListOrders")
+	defer func() { tracing.End(span, err) }()
 
-	if order, err := s.cache.Get(ctx, key); err == nil && order != nil {
-		return order, nil
+	if filter.UserID != nil {
+		err = s.policy.Authorize(ctx, policy.OrdersRead, *filter.UserID)
+	} else {
+		err = s.policy.Require(ctx, policy.OrdersRead)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	order, err := s.repo.GetOrderByUserID(ctx, user_id)
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultOrderPageSize
+	}
+	limit = min(limit, MaxOrderPageSize)
+	filter.Limit = limit + 1
+
+	orders, err := s.repo.ListOrders(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	_ = s.cache.Set(ctx, key, order, 15*time.Minute)
+	page := &entity.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = entity.CursorAfter(page.Orders[limit-1]).Encode()
+	}
 
-	return order, nil
+	return page, nil
 }
 
-func (s *OrderService) UpdateOrder(ctx context.Context, order *entity.Order) error {
+func (s *OrderService) UpdateOrder(ctx context.Context, order *entity.Order) (err error) {
+	ctx, span := startSpan(ctx, "OrderService.UpdateOrder")
+	defer func() { tracing.End(span, err) }()
+
 	if order == nil {
 		return ErrOrderNil
 	}
 
-	var total int64
-	for _, item := range order.Items {
-		total += item.Price * int64(item.Quantity)
+	existing, err := s.getOrder(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.Authorize(ctx, policy.OrdersWrite, existing.UserID); err != nil {
+		return err
+	}
+	// После оплаты состав заказа менять нельзя, иначе он разойдётся с платежом
+	if existing.Status != entity.OrderStatusPending {
+		return ErrOrderNotEditable
+	}
+	// Владельца и статус через обновление сменить нельзя
+	order.UserID = existing.UserID
+	order.Status = existing.Status
+
+	if err := validateItems(order.Items); err != nil {
+		return err
+	}
+	if err := s.resolveItems(ctx, order.Items, existing.Items); err != nil {
+		return err
 	}
-	order.Total = total
+	order.Total = orderTotal(order.Items)
 
 	if err := s.repo.UpdateOrder(ctx, order); err != nil {
 		return err
 	}
 
-	key := fmt.Sprintf("order:%d", order.ID)
-	_ = s.cache.Set(ctx, key, order, 15*time.Minute)
+	// Кешировать order нельзя: у новых позиций нет id, а updated_at выставляет БД
+	s.invalidateOrder(ctx, order.ID, order.UserID)
 
 	return nil
 }
 
-func (s *OrderService) DeleteOrder(ctx context.Context, id int64) error {
-	if err := s.repo.DeleteOrderByID(ctx, id); err != nil {
+// ownerDeletableStatuses владелец удаляет только неоплаченный заказ: удаление оплаченного
+// вернуло бы его резерв на склад и стёрло историю статусов
+var ownerDeletableStatuses = []entity.OrderStatus{entity.OrderStatusPending, entity.OrderStatusCancelled}
+
+func (s *OrderService) DeleteOrder(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "OrderService.DeleteOrder")
+	defer func() { tracing.End(span, err) }()
+
+	existing, err := s.getOrder(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var allowed []entity.OrderStatus
+	if s.policy.Require(ctx, policy.OrdersDelete) != nil {
+		if err := s.policy.Authorize(ctx, policy.OrdersDelete, existing.UserID); err != nil {
+			return err
+		}
+		if !slices.Contains(ownerDeletableStatuses, existing.Status) {
+			return policy.ErrForbidden
+		}
+		allowed = ownerDeletableStatuses
+	}
+
+	if err := s.repo.DeleteOrderByID(ctx, id, allowed); err != nil {
 		return err
 	}
 
-	key := fmt.Sprintf("order:%d", id)
-	_ = s.cache.Set(ctx, key, nil, 0)
+	s.invalidateOrder(ctx, id, existing.UserID)
+
+	return nil
+}
 
+// validateItems состав заказа проверяется здесь, а не тегами binding: gRPC и корзина идут мимо gin
+func validateItems(items []entity.OrderItem) error {
+	if len(items) == 0 {
+		return ErrOrderNoItems
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: %d", ErrInvalidQuantity, item.Quantity)
+		}
+	}
 	return nil
 }
+
+// resolveItems подставляет название и цену из каталога, значения от клиента не учитываются.
+// Позиции старых заказов без product_id сохраняют записанные при оформлении название и цену
+func (s *OrderService) resolveItems(ctx context.Context, items []entity.OrderItem, existing []entity.OrderItem) error {
+	stored := make(map[int64]entity.OrderItem, len(existing))
+	for _, item := range existing {
+		stored[item.ID] = item
+	}
+
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		if item.ProductID != 0 {
+			ids = append(ids, item.ProductID)
+		}
+	}
+
+	catalog := make(map[int64]entity.Product, len(ids))
+	if len(ids) > 0 {
+		products, err := s.products.GetByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			catalog[product.ID] = product
+		}
+	}
+
+	for i := range items {
+		item := &items[i]
+
+		if item.ProductID == 0 {
+			legacy, ok := stored[item.ID]
+			if item.ID == 0 || !ok || legacy.ProductID != 0 {
+				return fmt.Errorf("%w: item without product_id", ErrProductUnavailable)
+			}
+			item.Name = legacy.Name
+			item.Price = legacy.Price
+			continue
+		}
+
+		product, ok := catalog[item.ProductID]
+		if !ok || !product.Active {
+			return fmt.Errorf("%w: %d", ErrProductUnavailable, item.ProductID)
+		}
+		item.Name = product.Name
+		item.Price = product.Price
+	}
+
+	return nil
+}
+
+func orderTotal(items []entity.OrderItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.Price * int64(item.Quantity)
+	}
+	return total
+}
+
+// getOrder читает заказ через кеш без проверки прав. Одновременные промахи по заказу
+// дают один запрос в БД, ErrOrderNotFound кешируется ненадолго
+func (s *OrderService) getOrder(ctx context.Context, id int64) (*entity.Order, error) {
+	key := orderIDKey(id)
+
+	order, err := s.cache.Get(ctx, key)
+	if err == nil && order != nil {
+		return order, nil
+	}
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		return nil, err
+	}
+
+	return coalesce(ctx, &s.loads, key, func(ctx context.Context) (*entity.Order, error) {
+		order, err := s.repo.GetOrderByID(ctx, id)
+		if errors.Is(err, repositories.ErrOrderNotFound) {
+			_ = s.cache.SetNotFound(ctx, key, notFoundTTL)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_ = s.cache.Set(ctx, key, order, jitter(orderCacheTTL))
+		return order, nil
+	})
+}
+
+// invalidateOrder сбрасывает заказ и страницы истории его владельца после записи в БД
+func (s *OrderService) invalidateOrder(ctx context.Context, id, userID int64) {
+	_ = s.cache.Set(ctx, orderIDKey(id), nil, 0)
+	s.invalidateOrderLists(ctx, userID)
+}
+
+func (s *OrderService) invalidateOrderLists(ctx context.Context, userID int64) {
+	_ = s.cache.Set(ctx, userOrdersKey(userID), nil, 0)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/tracing"
+)
+
+var ErrUnknownOrderStatus = errors.New("unknown order status")
+
+// InvalidTransitionError возвращается, если из текущего статуса нельзя перейти в запрошенный
+type InvalidTransitionError struct {
+	From entity.OrderStatus
+	To   entity.OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+// orderTransitions граф переходов: pending → paid → shipped → delivered,
+// до оплаты заказ можно отменить, после оплаты только вернуть деньги
+var orderTransitions = map[entity.OrderStatus][]entity.OrderStatus{
+	entity.OrderStatusPending:   {entity.OrderStatusPaid, entity.OrderStatusCancelled},
+	entity.OrderStatusPaid:      {entity.OrderStatusShipped, entity.OrderStatusRefunded},
+	entity.OrderStatusShipped:   {entity.OrderStatusDelivered},
+	entity.OrderStatusDelivered: {entity.OrderStatusRefunded},
+	entity.OrderStatusCancelled: nil,
+	entity.OrderStatusRefunded:  nil,
+}
+
+func checkTransition(from, to entity.OrderStatus) error {
+	if _, ok := orderTransitions[to]; !ok {
+		return ErrUnknownOrderStatus
+	}
+
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: from, To: to}
+}
+
+// TransitionOrder переводит заказ в новый статус. Владелец может только отменить свой заказ,
+// остальные переходы требуют права orders:status
+func (s *OrderService) TransitionOrder(ctx context.Context, id int64, to entity.OrderStatus) (_ *entity.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderService.TransitionOrder")
+	defer func() { tracing.End(span, err) }()
+
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, policy.ErrUnauthenticated
+	}
+
+	// Право проверяем до чтения заказа, иначе по 404 и 403 можно перебрать чужие id.
+	// Отмену владельцем проверить можно только по самому заказу
+	cancel := to == entity.OrderStatusCancelled
+	if !cancel {
+		if err := s.policy.Require(ctx, policy.OrdersStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	// Статус читаем из БД, а не из кеша: решение о переходе должно опираться на свежие данные
+	order, err := s.repo.GetOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cancel {
+		if err := s.policy.Authorize(ctx, policy.OrdersStatus, order.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkTransition(order.Status, to); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.TransitionStatus(ctx, id, order.Status, to, identity.UserID); err != nil {
+		return nil, err
+	}
+	order.Status = to
+
+	s.invalidateOrder(ctx, id, order.UserID)
+
+	return order, nil
+}
+
+func (s *OrderService) GetStatusHistory(ctx context.Context, id int64) (_ []entity.OrderStatusChange, err error) {
+	ctx, span := startSpan(ctx, "OrderService.GetStatusHistory")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.GetOrderByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetStatusHistory(ctx, id)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to entity.OrderStatus
+		valid    bool
+	}{
+		{entity.OrderStatusPending, entity.OrderStatusPaid, true},
+		{entity.OrderStatusPending, entity.OrderStatusCancelled, true},
+		{entity.OrderStatusPaid, entity.OrderStatusShipped, true},
+		{entity.OrderStatusPaid, entity.OrderStatusRefunded, true},
+		{entity.OrderStatusShipped, entity.OrderStatusDelivered, true},
+		{entity.OrderStatusDelivered, entity.OrderStatusRefunded, true},
+		{entity.OrderStatusPending, entity.OrderStatusShipped, false},
+		{entity.OrderStatusPaid, entity.OrderStatusCancelled, false},
+		{entity.OrderStatusDelivered, entity.OrderStatusPending, false},
+		{entity.OrderStatusCancelled, entity.OrderStatusPaid, false},
+		{entity.OrderStatusRefunded, entity.OrderStatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			err := checkTransition(tt.from, tt.to)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			var transitionErr *InvalidTransitionError
+			assert.ErrorAs(t, err, &transitionErr)
+			assert.Equal(t, tt.from, transitionErr.From)
+			assert.Equal(t, tt.to, transitionErr.To)
+		})
+	}
+
+	assert.ErrorIs(t, checkTransition(entity.OrderStatusPending, "lost"), ErrUnknownOrderStatus)
+}
+
+func TestOrderService_TransitionOrder(t *testing.T) {
+	pending := func() *entity.Order {
+		return &entity.Order{ID: 5, UserID: 1, Status: entity.OrderStatusPending}
+	}
+
+	t.Run("owner cancels pending order", func(t *testing.T) {
+		repo, cache := new(MockOrderRepo), new(MockOrderCache)
+		service := NewOrderService(repo, new(MockProductRepo), cache, testPolicy())
+		ctx := withIdentity(1, auth.RoleCustomer)
+
+		repo.On("GetOrderByID", mock.Anything, int64(5)).Return(pending(), nil)
+		repo.On("TransitionStatus", ctx, int64(5), entity.OrderStatusPending, entity.OrderStatusCancelled, int64(1)).Return(nil)
+		cache.On("Set", ctx, orderIDKey(5), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+		cache.On("Set", ctx, userOrdersKey(1), (*entity.Order)(nil), time.Duration(0)).Return(nil)
+
+		order, err := service.TransitionOrder(ctx, 5, entity.OrderStatusCancelled)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entity.OrderStatusCancelled, order.Status)
+		repo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("owner cannot mark order paid", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+		ctx := withIdentity(1, auth.RoleCustomer)
+
+		_, err := service.TransitionOrder(ctx, 5, entity.OrderStatusPaid)
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "GetOrderByID", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing order is not revealed without permission", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+
+		_, err := service.TransitionOrder(withIdentity(1, auth.RoleCustomer), 404, entity.OrderStatusShipped)
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "GetOrderByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("stranger cannot cancel order", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+
+		repo.On("GetOrderByID", mock.Anything, int64(5)).Return(pending(), nil)
+
+		_, err := service.TransitionOrder(withIdentity(2, auth.RoleCustomer), 5, entity.OrderStatusCancelled)
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("support cannot skip payment", func(t *testing.T) {
+		repo := new(MockOrderRepo)
+		service := NewOrderService(repo, new(MockProductRepo), new(MockOrderCache), testPolicy())
+		ctx := withIdentity(2, auth.RoleSupport)
+
+		repo.On("GetOrderByID", mock.Anything, int64(5)).Return(pending(), nil)
+
+		_, err := service.TransitionOrder(ctx, 5, entity.OrderStatusShipped)
+
+		var transitionErr *InvalidTransitionError
+		assert.ErrorAs(t, err, &transitionErr)
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+)
+
+type ProductService struct {
+	repo   repositories.ProductRepository
+	policy *policy.Policy
+}
+
+func NewProductService(repo repositories.ProductRepository, policy *policy.Policy) *ProductService {
+	return &ProductService{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+func (s *ProductService) CreateProduct(ctx context.Context, product *entity.Product) (*entity.Product, error) {
+	if err := s.policy.Require(ctx, policy.ProductsWrite); err != nil {
+		return nil, err
+	}
+
+	if err := product.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repo.Create(ctx, product)
+}
+
+// GetProduct снятый с продажи товар для покупателей не существует, его видят только те, кто управляет каталогом
+func (s *ProductService) GetProduct(ctx context.Context, id int64) (*entity.Product, error) {
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !product.Active && s.policy.Require(ctx, policy.ProductsWrite) != nil {
+		return nil, repositories.ErrProductNotFound
+	}
+	return product, nil
+}
+
+// ListProducts отдаёт витрину, снятые с продажи товары видят только те, кто управляет каталогом
+func (s *ProductService) ListProducts(ctx context.Context, includeInactive bool, limit, offset int) ([]entity.Product, error) {
+	if includeInactive {
+		if err := s.policy.Require(ctx, policy.ProductsWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.List(ctx, !includeInactive, limit, offset)
+}
+
+func (s *ProductService) UpdateProduct(ctx context.Context, product *entity.Product) error {
+	if err := s.policy.Require(ctx, policy.ProductsWrite); err != nil {
+		return err
+	}
+
+	if err := product.Validate(); err != nil {
+		return err
+	}
+
+	return s.repo.Update(ctx, product)
+}
+
+// AdjustStock приход или списание остатка. Stock в UpdateProduct игнорируется
+func (s *ProductService) AdjustStock(ctx context.Context, id int64, delta int) (int, error) {
+	if err := s.policy.Require(ctx, policy.ProductsWrite); err != nil {
+		return 0, err
+	}
+
+	return s.repo.AdjustStock(ctx, id, delta)
+}
+
+func (s *ProductService) DeleteProduct(ctx context.Context, id int64) error {
+	if err := s.policy.Require(ctx, policy.ProductsWrite); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(ctx, id)
+}
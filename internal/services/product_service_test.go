@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/auth"
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockProductRepo struct{ mock.Mock }
+
+func (m *MockProductRepo) Create(ctx context.Context, product *entity.Product) (*entity.Product, error) {
+	args := m.Called(ctx, product)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) GetByID(ctx context.Context, id int64) (*entity.Product, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) GetByIDs(ctx context.Context, ids []int64) ([]entity.Product, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]entity.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) List(ctx context.Context, activeOnly bool, limit, offset int) ([]entity.Product, error) {
+	args := m.Called(ctx, activeOnly, limit, offset)
+	return args.Get(0).([]entity.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Update(ctx context.Context, product *entity.Product) error {
+	return m.Called(ctx, product).Error(0)
+}
+
+func (m *MockProductRepo) AdjustStock(ctx context.Context, id int64, delta int) (int, error) {
+	args := m.Called(ctx, id, delta)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockProductRepo) Delete(ctx context.Context, id int64) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func TestProductService(t *testing.T) {
+	t.Run("customer cannot create product", func(t *testing.T) {
+		repo := new(MockProductRepo)
+		service := NewProductService(repo, testPolicy())
+
+		_, err := service.CreateProduct(withIdentity(1, auth.RoleCustomer), &entity.Product{SKU: "A-1", Name: "book", Price: 100})
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin creates validated product", func(t *testing.T) {
+		repo := new(MockProductRepo)
+		service := NewProductService(repo, testPolicy())
+		product := &entity.Product{SKU: " A-1 ", Name: "book", Price: 100, Active: true}
+
+		repo.On("Create", mock.Anything, product).Return(product, nil)
+
+		res, err := service.CreateProduct(withIdentity(1, auth.RoleAdmin), product)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "A-1", res.SKU)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("rejects negative price", func(t *testing.T) {
+		service := NewProductService(new(MockProductRepo), testPolicy())
+
+		err := service.UpdateProduct(withIdentity(1, auth.RoleAdmin), &entity.Product{ID: 1, SKU: "A-1", Name: "book", Price: -1})
+
+		assert.ErrorIs(t, err, entity.ErrInvalidPrice)
+	})
+
+	t.Run("anonymous sees only active products", func(t *testing.T) {
+		repo := new(MockProductRepo)
+		service := NewProductService(repo, testPolicy())
+
+		repo.On("List", mock.Anything, true, 50, 0).Return([]entity.Product{{ID: 1}}, nil)
+
+		products, err := service.ListProducts(context.Background(), false, 50, 0)
+		assert.NoError(t, err)
+		assert.Len(t, products, 1)
+
+		_, err = service.ListProducts(context.Background(), true, 50, 0)
+		assert.ErrorIs(t, err, policy.ErrUnauthenticated)
+	})
+
+	t.Run("inactive product is hidden from customers", func(t *testing.T) {
+		repo := new(MockProductRepo)
+		service := NewProductService(repo, testPolicy())
+		product := &entity.Product{ID: 1, Name: "book", Active: false}
+
+		repo.On("GetByID", mock.Anything, int64(1)).Return(product, nil)
+
+		_, err := service.GetProduct(context.Background(), 1)
+		assert.ErrorIs(t, err, repositories.ErrProductNotFound)
+
+		_, err = service.GetProduct(withIdentity(2, auth.RoleCustomer), 1)
+		assert.ErrorIs(t, err, repositories.ErrProductNotFound)
+
+		res, err := service.GetProduct(withIdentity(1, auth.RoleAdmin), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, product, res)
+	})
+
+	t.Run("stock is adjusted only by catalog managers", func(t *testing.T) {
+		repo := new(MockProductRepo)
+		service := NewProductService(repo, testPolicy())
+
+		_, err := service.AdjustStock(withIdentity(1, auth.RoleCustomer), 1, 5)
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "AdjustStock", mock.Anything, mock.Anything, mock.Anything)
+
+		repo.On("AdjustStock", mock.Anything, int64(1), -2).Return(3, nil)
+		stock, err := service.AdjustStock(withIdentity(1, auth.RoleAdmin), 1, -2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, stock)
+	})
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan span метода сервиса, между HTTP и репозиторием. Вне трейса (фоновые задачи, тесты)
+// контекст не меняется, чтобы не плодить корневые трейсы из одного span
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return otel.Tracer("github.com/Belixk/CommerceTwo/internal/services").Start(ctx, name)
+}
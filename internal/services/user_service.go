@@ -2,16 +2,28 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
 	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/Belixk/CommerceTwo/internal/tracing"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrUnknownRole      = errors.New("unknown role")
+	ErrPasswordTooShort = errors.New("password is too short")
 )
 
+// UserCache Get возвращает repositories.ErrUserNotFound, если под ключом закеширован промах
 type UserCache interface {
 	Get(ctx context.Context, key string) (*entity.User, error)
 	Set(ctx context.Context, key string, user *entity.User, ttl time.Duration) error
+	SetNotFound(ctx context.Context, key string, ttl time.Duration) error
 }
 
 type PasswordHasher interface {
@@ -20,26 +32,34 @@ type PasswordHasher interface {
 }
 
 type UserService struct {
-	repo   repositories.UserRepository
-	cache  UserCache
-	hasher PasswordHasher
+	repo       repositories.UserRepository
+	cache      UserCache
+	orderCache Cache // заказы удаляются вместе с пользователем, их записи надо сбросить
+	hasher     PasswordHasher
+	policy     *policy.Policy
+	loads      singleflight.Group
 }
 
-func NewUserService(repo repositories.UserRepository, cache UserCache, hasher PasswordHasher) *UserService {
+func NewUserService(repo repositories.UserRepository, cache UserCache, orderCache Cache, hasher PasswordHasher, policy *policy.Policy) *UserService {
 	return &UserService{
-		repo:   repo,
-		cache:  cache,
-		hasher: hasher,
+		repo:       repo,
+		cache:      cache,
+		orderCache: orderCache,
+		hasher:     hasher,
+		policy:     policy,
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, user *entity.User, password string) (*entity.User, error) {
+func (s *UserService) CreateUser(ctx context.Context, user *entity.User, password string) (_ *entity.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.CreateUser")
+	defer func() { tracing.End(span, err) }()
+
 	if err := user.Validate(); err != nil {
 		return nil, err
 	}
 
 	if len(password) < 6 {
-		return nil, fmt.Errorf("password is too short")
+		return nil, ErrPasswordTooShort
 	}
 
 	hash, err := s.hasher.Hash(password)
@@ -47,58 +67,161 @@ func (s *UserService) CreateUser(ctx context.Context, user *entity.User, passwor
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 	user.PasswordHash = hash
-	return s.repo.Create(ctx, user)
+
+	created, err := s.repo.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Под этим email или id мог быть закеширован промах
+	s.invalidateUser(ctx, created)
+	return created, nil
 }
 
-func (s *UserService) GetUserById(ctx context.Context, id int64) (*entity.User, error) {
-	key := fmt.Sprintf("user:%d", id)
+func (s *UserService) GetUserById(ctx context.Context, id int64) (_ *entity.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUserById")
+	defer func() { tracing.End(span, err) }()
 
-	if user, err := s.cache.Get(ctx, key); err == nil && user != nil {
-		return user, nil
+	if err := s.policy.Authorize(ctx, policy.UsersRead, id); err != nil {
+		return nil, err
 	}
 
-	user, err := s.repo.GetByID(ctx, id)
-	if err != nil {
+	return s.loadUser(ctx, userIDKey(id), func(ctx context.Context) (*entity.User, error) {
+		return s.repo.GetByID(ctx, id)
+	})
+}
+
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (_ *entity.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUserByEmail")
+	defer func() { tracing.End(span, err) }()
+
+	return s.loadUser(ctx, userEmailKey(email), func(ctx context.Context) (*entity.User, error) {
+		return s.repo.GetByEmail(ctx, email)
+	})
+}
+
+func (s *UserService) ListUsers(ctx context.Context, limit, offset int) (_ []entity.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Require(ctx, policy.UsersList); err != nil {
 		return nil, err
 	}
 
-	_ = s.cache.Set(ctx, key, user, 15*time.Minute)
+	return s.repo.List(ctx, limit, offset)
+}
+
+func (s *UserService) UpdateUser(ctx context.Context, user *entity.User) (err error) {
+	ctx, span := startSpan(ctx, "UserService.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Authorize(ctx, policy.UsersWrite, user.ID); err != nil {
+		return err
+	}
+
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	// Старый email нужен, чтобы сбросить запись под ним, если адрес меняется
+	existing, err := s.repo.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
 
-	return user, nil
+	if err := s.repo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	// Входные данные не кешируем: в них нет роли и дат, следующее чтение возьмёт строку из БД
+	s.invalidateUser(ctx, existing, user)
+
+	return nil
 }
 
-func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
-	key := fmt.Sprintf("user:%s", email)
+// SetRole меняет роль пользователя, новая роль попадёт в токен при следующем refresh
+func (s *UserService) SetRole(ctx context.Context, id int64, role string) (err error) {
+	ctx, span := startSpan(ctx, "UserService.SetRole")
+	defer func() { tracing.End(span, err) }()
 
-	if user, err := s.cache.Get(ctx, key); err == nil && user != nil {
-		return user, nil
+	if err := s.policy.Require(ctx, policy.UsersManageRoles); err != nil {
+		return err
+	}
+
+	if !s.policy.RoleExists(role) {
+		return ErrUnknownRole
 	}
 
-	user, err := s.repo.GetByEmail(ctx, email)
+	existing, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := s.repo.UpdateRole(ctx, id, role); err != nil {
+		return err
 	}
 
-	_ = s.cache.Set(ctx, key, user, time.Minute*15)
-	return user, nil
+	s.invalidateUser(ctx, existing)
+	return nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, user *entity.User) error {
-	if err := user.Validate(); err != nil {
+func (s *UserService) DeleteUser(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "UserService.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Authorize(ctx, policy.UsersDelete, id); err != nil {
 		return err
 	}
 
-	if err := s.repo.Update(ctx, user); err != nil {
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
 		return err
 	}
 
-	key := fmt.Sprintf("user:%d", user.ID)
-	_ = s.cache.Set(ctx, key, user, 15*time.Minute)
+	orderIDs, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
 
+	s.invalidateUser(ctx, existing)
+	for _, orderID := range orderIDs {
+		_ = s.orderCache.Set(ctx, orderIDKey(orderID), nil, 0)
+	}
+	_ = s.orderCache.Set(ctx, userOrdersKey(id), nil, 0)
 	return nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
-	_ = s.cache.Set(ctx, fmt.Sprintf("user:%d", id), nil, 0)
-	return s.repo.Delete(ctx, id)
+// loadUser читает пользователя через кеш. Одновременные промахи по ключу дают один запрос в БД,
+// ErrUserNotFound тоже кешируется, но ненадолго
+func (s *UserService) loadUser(ctx context.Context, key string, fetch func(ctx context.Context) (*entity.User, error)) (*entity.User, error) {
+	user, err := s.cache.Get(ctx, key)
+	if err == nil && user != nil {
+		return user, nil
+	}
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		return nil, err
+	}
+
+	return coalesce(ctx, &s.loads, key, func(ctx context.Context) (*entity.User, error) {
+		user, err := fetch(ctx)
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			_ = s.cache.SetNotFound(ctx, key, notFoundTTL)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_ = s.cache.Set(ctx, key, user, jitter(userCacheTTL))
+		return user, nil
+	})
+}
+
+// invalidateUser сбрасывает пользователя под всеми индексами. Вызывается после записи в БД,
+// иначе параллельное чтение успеет положить в кеш старую строку
+func (s *UserService) invalidateUser(ctx context.Context, users ...*entity.User) {
+	for _, user := range users {
+		for _, key := range userKeys(user) {
+			_ = s.cache.Set(ctx, key, nil, 0)
+		}
+	}
 }
@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/Belixk/CommerceTwo/internal/auth"
 	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/policy"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockUserRepo struct{ mock.Mock }
@@ -26,10 +31,30 @@ func (m *MockUserRepo) GetByID(ctx context.Context, id int64) (*entity.User, err
 }
 
 func (m *MockUserRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
-	return nil, nil
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+func (m *MockUserRepo) List(ctx context.Context, limit, offset int) ([]entity.User, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *MockUserRepo) Update(ctx context.Context, user *entity.User) error {
+	return m.Called(ctx, user).Error(0)
+}
+
+func (m *MockUserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	return m.Called(ctx, id, role).Error(0)
+}
+
+func (m *MockUserRepo) Delete(ctx context.Context, id int64) ([]int64, error) {
+	args := m.Called(ctx, id)
+	orderIDs, _ := args.Get(0).([]int64)
+	return orderIDs, args.Error(1)
 }
-func (m *MockUserRepo) Update(ctx context.Context, user *entity.User) error { return nil }
-func (m *MockUserRepo) Delete(ctx context.Context, id int64) error          { return nil }
 
 type MockHasher struct{ mock.Mock }
 
@@ -37,7 +62,9 @@ func (m *MockHasher) Hash(p string) (string, error) {
 	args := m.Called(p)
 	return args.String(0), args.Error(1)
 }
-func (m *MockHasher) Compare(h, p string) error { return nil }
+func (m *MockHasher) Compare(h, p string) error {
+	return m.Called(h, p).Error(0)
+}
 
 type MockCache struct{ mock.Mock }
 
@@ -53,16 +80,27 @@ func (m *MockCache) Set(ctx context.Context, k string, u *entity.User, t time.Du
 	return nil
 }
 
+func (m *MockCache) SetNotFound(ctx context.Context, k string, t time.Duration) error {
+	return nil
+}
+
+// invalidatingOrderCache кеш заказов, который принимает любые инвалидации
+func invalidatingOrderCache() *MockOrderCache {
+	orders := new(MockOrderCache)
+	orders.On("Set", mock.Anything, mock.Anything, (*entity.Order)(nil), time.Duration(0)).Return(nil)
+	return orders
+}
+
 func TestUserService_CreateUser(t *testing.T) {
 	repo := new(MockUserRepo)
 	hasher := new(MockHasher)
 	cache := new(MockCache)
-	service := NewUserService(repo, cache, hasher)
+	service := NewUserService(repo, cache, new(MockOrderCache), hasher, testPolicy())
 
 	ctx := context.Background()
 
 	t.Run("short password error", func(t *testing.T) {
-		user := &entity.User{Email: "test@test.com"}
+		user := &entity.User{FirstName: "Maxim", LastName: "Ivanov", Email: "test@test.com"}
 		res, err := service.CreateUser(ctx, user, "123")
 
 		assert.Error(t, err)
@@ -71,7 +109,7 @@ func TestUserService_CreateUser(t *testing.T) {
 	})
 
 	t.Run("succes create", func(t *testing.T) {
-		user := &entity.User{FirstName: "Maxim", Email: "maxim@test.com"}
+		user := &entity.User{FirstName: "Maxim", LastName: "Ivanov", Email: "maxim@test.com"}
 		password := "123456"
 		hashedPassword := "hashed_123456"
 
@@ -88,3 +126,224 @@ func TestUserService_CreateUser(t *testing.T) {
 		hasher.AssertExpectations(t)
 	})
 }
+
+func TestUserService_DeleteUser_Policy(t *testing.T) {
+	t.Run("customer cannot delete another user", func(t *testing.T) {
+		repo := new(MockUserRepo)
+		service := NewUserService(repo, new(MockCache), new(MockOrderCache), new(MockHasher), testPolicy())
+		ctx := withIdentity(1, auth.RoleCustomer)
+
+		err := service.DeleteUser(ctx, 2)
+
+		assert.ErrorIs(t, err, policy.ErrForbidden)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("customer deletes own account", func(t *testing.T) {
+		repo := new(MockUserRepo)
+		service := NewUserService(repo, new(MockCache), invalidatingOrderCache(), new(MockHasher), testPolicy())
+		ctx := withIdentity(1, auth.RoleCustomer)
+		repo.On("GetByID", ctx, int64(1)).Return(&entity.User{ID: 1}, nil)
+		repo.On("Delete", ctx, int64(1)).Return([]int64(nil), nil)
+
+		assert.NoError(t, service.DeleteUser(ctx, 1))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("admin deletes any account", func(t *testing.T) {
+		repo := new(MockUserRepo)
+		service := NewUserService(repo, new(MockCache), invalidatingOrderCache(), new(MockHasher), testPolicy())
+		ctx := withIdentity(1, auth.RoleAdmin)
+		repo.On("GetByID", ctx, int64(2)).Return(&entity.User{ID: 2}, nil)
+		repo.On("Delete", ctx, int64(2)).Return([]int64(nil), nil)
+
+		assert.NoError(t, service.DeleteUser(ctx, 2))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		service := NewUserService(new(MockUserRepo), new(MockCache), new(MockOrderCache), new(MockHasher), testPolicy())
+
+		err := service.DeleteUser(context.Background(), 1)
+
+		assert.ErrorIs(t, err, policy.ErrUnauthenticated)
+	})
+}
+
+func TestUserService_ListUsers_Policy(t *testing.T) {
+	repo := new(MockUserRepo)
+	service := NewUserService(repo, new(MockCache), new(MockOrderCache), new(MockHasher), testPolicy())
+
+	_, err := service.ListUsers(withIdentity(1, auth.RoleCustomer), 10, 0)
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+
+	ctx := withIdentity(1, auth.RoleSupport)
+	repo.On("List", ctx, 10, 0).Return([]entity.User{{ID: 1}, {ID: 2}}, nil)
+
+	users, err := service.ListUsers(ctx, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+}
+
+func TestUserService_SetRole(t *testing.T) {
+	repo := new(MockUserRepo)
+	service := NewUserService(repo, new(MockCache), new(MockOrderCache), new(MockHasher), testPolicy())
+
+	err := service.SetRole(withIdentity(1, auth.RoleSupport), 2, auth.RoleAdmin)
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+
+	err = service.SetRole(withIdentity(1, auth.RoleAdmin), 2, "superuser")
+	assert.ErrorIs(t, err, ErrUnknownRole)
+}
+
+// testPolicy повторяет права из миграций 004_create_roles и 006_order_status
+func testPolicy() *policy.Policy {
+	return policy.New(map[string][]policy.Permission{
+		auth.RoleCustomer: nil,
+		auth.RoleSupport:  {policy.UsersRead, policy.UsersList, policy.OrdersRead, policy.OrdersStatus},
+		auth.RoleAdmin: {
+			policy.UsersRead, policy.UsersList, policy.UsersWrite, policy.UsersDelete, policy.UsersManageRoles,
+			policy.OrdersRead, policy.OrdersWrite, policy.OrdersDelete, policy.OrdersStatus,
+			policy.ProductsWrite,
+		},
+	})
+}
+
+func withIdentity(userID int64, role string) context.Context {
+	return auth.WithIdentity(context.Background(), auth.Identity{UserID: userID, Role: role})
+}
+
+// memUserCache кеш в памяти, по нему видно, какие записи остались после операции
+type memUserCache map[string]*entity.User
+
+func (c memUserCache) Get(_ context.Context, key string) (*entity.User, error) {
+	user, ok := c[key]
+	if !ok {
+		return nil, errors.New("miss")
+	}
+	if user == nil {
+		return nil, repositories.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (c memUserCache) Set(_ context.Context, key string, user *entity.User, _ time.Duration) error {
+	if user == nil {
+		delete(c, key)
+		return nil
+	}
+	c[key] = user
+	return nil
+}
+
+func (c memUserCache) SetNotFound(_ context.Context, key string, _ time.Duration) error {
+	c[key] = nil
+	return nil
+}
+
+func TestUserService_CacheInvalidation(t *testing.T) {
+	stored := func() *entity.User {
+		return &entity.User{ID: 2, FirstName: "Maxim", LastName: "Ivanov", Email: "old@test.com", Role: auth.RoleCustomer}
+	}
+	// warm кладёт пользователя под оба индекса, как это делают чтения
+	warm := func(t *testing.T, service *UserService, repo *MockUserRepo) {
+		t.Helper()
+		ctx := withIdentity(2, auth.RoleCustomer)
+		repo.On("GetByID", mock.Anything, int64(2)).Return(stored(), nil)
+		repo.On("GetByEmail", mock.Anything, "old@test.com").Return(stored(), nil)
+
+		_, err := service.GetUserById(ctx, 2)
+		require.NoError(t, err)
+		_, err = service.GetUserByEmail(ctx, "old@test.com")
+		require.NoError(t, err)
+	}
+
+	t.Run("reads populate namespaced keys", func(t *testing.T) {
+		repo, cache := new(MockUserRepo), memUserCache{}
+		service := NewUserService(repo, cache, new(MockOrderCache), new(MockHasher), testPolicy())
+
+		warm(t, service, repo)
+
+		assert.Contains(t, cache, "commerce:v1:user:id:2")
+		assert.Contains(t, cache, "commerce:v1:user:email:old@test.com")
+
+		// Повторное чтение идёт из кеша
+		_, _ = service.GetUserById(withIdentity(2, auth.RoleCustomer), 2)
+		repo.AssertNumberOfCalls(t, "GetByID", 1)
+	})
+
+	t.Run("missing user is cached until created", func(t *testing.T) {
+		repo, cache, hasher := new(MockUserRepo), memUserCache{}, new(MockHasher)
+		service := NewUserService(repo, cache, new(MockOrderCache), hasher, testPolicy())
+		ctx := context.Background()
+		repo.On("GetByEmail", mock.Anything, "new@test.com").Return(nil, repositories.ErrUserNotFound).Once()
+
+		_, err := service.GetUserByEmail(ctx, "new@test.com")
+		assert.ErrorIs(t, err, repositories.ErrUserNotFound)
+		_, err = service.GetUserByEmail(ctx, "new@test.com")
+		assert.ErrorIs(t, err, repositories.ErrUserNotFound)
+		repo.AssertNumberOfCalls(t, "GetByEmail", 1)
+
+		user := &entity.User{FirstName: "Maxim", LastName: "Ivanov", Email: "new@test.com"}
+		hasher.On("Hash", "123456").Return("hash", nil)
+		repo.On("Create", mock.Anything, user).Return(&entity.User{ID: 9, Email: "new@test.com"}, nil)
+
+		_, err = service.CreateUser(ctx, user, "123456")
+		require.NoError(t, err)
+		assert.Empty(t, cache)
+	})
+
+	t.Run("update drops old and new email and does not cache input", func(t *testing.T) {
+		repo, cache := new(MockUserRepo), memUserCache{}
+		service := NewUserService(repo, cache, new(MockOrderCache), new(MockHasher), testPolicy())
+		warm(t, service, repo)
+		cache[userEmailKey("new@test.com")] = &entity.User{ID: 3}
+
+		update := &entity.User{ID: 2, FirstName: "Max", LastName: "Ivanov", Email: "new@test.com"}
+		repo.On("Update", mock.Anything, update).Return(nil)
+
+		require.NoError(t, service.UpdateUser(withIdentity(2, auth.RoleCustomer), update))
+
+		assert.Empty(t, cache)
+	})
+
+	t.Run("failed update keeps cache", func(t *testing.T) {
+		repo, cache := new(MockUserRepo), memUserCache{}
+		service := NewUserService(repo, cache, new(MockOrderCache), new(MockHasher), testPolicy())
+		warm(t, service, repo)
+
+		update := &entity.User{ID: 2, FirstName: "Max", LastName: "Ivanov", Email: "taken@test.com"}
+		repo.On("Update", mock.Anything, update).Return(repositories.ErrEmailExists)
+
+		err := service.UpdateUser(withIdentity(2, auth.RoleCustomer), update)
+
+		assert.ErrorIs(t, err, repositories.ErrEmailExists)
+		assert.Len(t, cache, 2)
+	})
+
+	t.Run("role change drops all indexes", func(t *testing.T) {
+		repo, cache := new(MockUserRepo), memUserCache{}
+		service := NewUserService(repo, cache, new(MockOrderCache), new(MockHasher), testPolicy())
+		warm(t, service, repo)
+		repo.On("UpdateRole", mock.Anything, int64(2), auth.RoleSupport).Return(nil)
+
+		require.NoError(t, service.SetRole(withIdentity(1, auth.RoleAdmin), 2, auth.RoleSupport))
+
+		assert.Empty(t, cache)
+	})
+
+	t.Run("delete drops all indexes and cascaded orders", func(t *testing.T) {
+		repo, cache, orders := new(MockUserRepo), memUserCache{}, new(MockOrderCache)
+		service := NewUserService(repo, cache, orders, new(MockHasher), testPolicy())
+		warm(t, service, repo)
+		repo.On("Delete", mock.Anything, int64(2)).Return([]int64{7, 8}, nil)
+		for _, key := range []string{orderIDKey(7), orderIDKey(8), userOrdersKey(2)} {
+			orders.On("Set", mock.Anything, key, (*entity.Order)(nil), time.Duration(0)).Return(nil).Once()
+		}
+
+		require.NoError(t, service.DeleteUser(withIdentity(2, auth.RoleCustomer), 2))
+
+		assert.Empty(t, cache)
+		orders.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startQuery открывает span запроса к Postgres, имя как у метода репозитория
+func startQuery(ctx context.Context, repository, method string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", method),
+		),
+	)
+}
+
+type userRepository struct {
+	next repositories.UserRepository
+}
+
+// InstrumentUserRepository пишет span на каждый метод репозитория пользователей
+func InstrumentUserRepository(next repositories.UserRepository) repositories.UserRepository {
+	return &userRepository{next: next}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity.User, error) {
+	ctx, span := startQuery(ctx, "UserRepository", "Create")
+	result, err := r.next.Create(ctx, user)
+	End(span, err)
+	return result, err
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+	ctx, span := startQuery(ctx, "UserRepository", "GetByID")
+	result, err := r.next.GetByID(ctx, id)
+	End(span, err)
+	return result, err
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	ctx, span := startQuery(ctx, "UserRepository", "GetByEmail")
+	result, err := r.next.GetByEmail(ctx, email)
+	End(span, err)
+	return result, err
+}
+
+func (r *userRepository) List(ctx context.Context, limit, offset int) ([]entity.User, error) {
+	ctx, span := startQuery(ctx, "UserRepository", "List")
+	result, err := r.next.List(ctx, limit, offset)
+	End(span, err)
+	return result, err
+}
+
+func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
+	ctx, span := startQuery(ctx, "UserRepository", "Update")
+	err := r.next.Update(ctx, user)
+	End(span, err)
+	return err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	ctx, span := startQuery(ctx, "UserRepository", "UpdateRole")
+	err := r.next.UpdateRole(ctx, id, role)
+	End(span, err)
+	return err
+}
+
+func (r *userRepository) Delete(ctx context.Context, id int64) ([]int64, error) {
+	ctx, span := startQuery(ctx, "UserRepository", "Delete")
+	orderIDs, err := r.next.Delete(ctx, id)
+	End(span, err)
+	return orderIDs, err
+}
+
+type orderRepository struct {
+	next repositories.OrderRepository
+}
+
+// InstrumentOrderRepository пишет span на каждый метод репозитория заказов
+func InstrumentOrderRepository(next repositories.OrderRepository) repositories.OrderRepository {
+	return &orderRepository{next: next}
+}
+
+func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	ctx, span := startQuery(ctx, "OrderRepository", "CreateOrder")
+	result, err := r.next.CreateOrder(ctx, order)
+	End(span, err)
+	return result, err
+}
+
+func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	ctx, span := startQuery(ctx, "OrderRepository", "GetOrderByID")
+	result, err := r.next.GetOrderByID(ctx, id)
+	End(span, err)
+	return result, err
+}
+
+func (r *orderRepository) ListOrdersByUserID(ctx context.Context, userID int64, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	ctx, span := startQuery(ctx, "OrderRepository", "ListOrdersByUserID")
+	result, err := r.next.ListOrdersByUserID(ctx, userID, after, limit)
+	End(span, err)
+	return result, err
+}
+
+func (r *orderRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, error) {
+	ctx, span := startQuery(ctx, "OrderRepository", "ListOrders")
+	result, err := r.next.ListOrders(ctx, filter)
+	End(span, err)
+	return result, err
+}
+
+func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order) error {
+	ctx, span := startQuery(ctx, "OrderRepository", "UpdateOrder")
+	err := r.next.UpdateOrder(ctx, order)
+	End(span, err)
+	return err
+}
+
+func (r *orderRepository) DeleteOrderByID(ctx context.Context, id int64, allowed []entity.OrderStatus) error {
+	ctx, span := startQuery(ctx, "OrderRepository", "DeleteOrderByID")
+	err := r.next.DeleteOrderByID(ctx, id, allowed)
+	End(span, err)
+	return err
+}
+
+func (r *orderRepository) TransitionStatus(ctx context.Context, id int64, from, to entity.OrderStatus, changedBy int64) error {
+	ctx, span := startQuery(ctx, "OrderRepository", "TransitionStatus")
+	err := r.next.TransitionStatus(ctx, id, from, to, changedBy)
+	End(span, err)
+	return err
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusChange, error) {
+	ctx, span := startQuery(ctx, "OrderRepository", "GetStatusHistory")
+	result, err := r.next.GetStatusHistory(ctx, orderID)
+	End(span, err)
+	return result, err
+}
@@ -0,0 +1,97 @@
+// Package tracing настраивает OpenTelemetry и даёт обёртки, которые пишут spans
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ServiceName = "commerce-api"
+
+// Экспортёры, которые можно выбрать в конфиге
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const instrumentationName = "github.com/Belixk/CommerceTwo/internal/tracing"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NewExporter собирает экспортёр по имени. stdout пишет JSON в файл, если он задан,
+// otlp шлёт spans по OTLP/HTTP на endpoint (host:port) коллектора
+func NewExporter(ctx context.Context, kind, file, endpoint string) (sdktrace.SpanExporter, func() error, error) {
+	noop := func() error { return nil }
+
+	switch kind {
+	case ExporterNone, "":
+		return nil, noop, nil
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		closeFile := noop
+		if file != "" {
+			f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("open trace file: %w", err)
+			}
+			w, closeFile = f, f.Close
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, nil, err
+		}
+		return exporter, closeFile, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+		if err != nil {
+			return nil, nil, err
+		}
+		return exporter, noop, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", kind)
+	}
+}
+
+// NewProvider регистрирует глобальный TracerProvider и W3C propagator (traceparent, baggage).
+// Без экспортёра spans всё равно создаются, trace_id попадает в логи
+func NewProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider
+}
+
+// End закрывает span и помечает его ошибкой, если операция вернула ошибку
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Belixk/CommerceTwo/internal/entity"
+	"github.com/Belixk/CommerceTwo/internal/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupRecorder ставит глобальный провайдер, который складывает законченные spans в память
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+func TestGinMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := setupRecorder(t)
+
+	var handlerSpan trace.SpanContext
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/orders/:id", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+
+	assert.Equal(t, "GET /orders/:id", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+}
+
+type fakeUserRepo struct {
+	repositories.UserRepository
+	err error
+}
+
+func (f *fakeUserRepo) GetByID(_ context.Context, id int64) (*entity.User, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &entity.User{ID: id}, nil
+}
+
+func TestInstrumentUserRepository(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "UserService.GetUserByID")
+	_, _ = InstrumentUserRepository(&fakeUserRepo{}).GetByID(ctx, 1)
+	_, _ = InstrumentUserRepository(&fakeUserRepo{err: repositories.ErrUserNotFound}).GetByID(ctx, 2)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	ok, failed := spans[0], spans[1]
+	assert.Equal(t, "UserRepository.GetByID", ok.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), ok.Parent().SpanID())
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, repositories.ErrUserNotFound.Error(), failed.Status().Description)
+}
+
+func TestNewExporter(t *testing.T) {
+	ctx := context.Background()
+
+	exporter, closeOutput, err := NewExporter(ctx, ExporterNone, "", "")
+	require.NoError(t, err)
+	assert.Nil(t, exporter)
+	assert.NoError(t, closeOutput())
+
+	file := t.TempDir() + "/traces.json"
+	exporter, closeOutput, err = NewExporter(ctx, ExporterStdout, file, "")
+	require.NoError(t, err)
+	assert.NotNil(t, exporter)
+	assert.NoError(t, exporter.Shutdown(ctx))
+	assert.NoError(t, closeOutput())
+
+	_, _, err = NewExporter(ctx, "zipkin", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+
+    PRIMARY KEY (role, permission),
+    CONSTRAINT fk_role FOREIGN KEY(role) REFERENCES roles(name) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('customer', 'Покупатель, работает только со своими данными'),
+    ('support', 'Поддержка, видит всех пользователей и заказы'),
+    ('admin', 'Администратор, полный доступ')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('support', 'users:read'),
+    ('support', 'users:list'),
+    ('support', 'orders:read'),
+    ('admin', 'users:read'),
+    ('admin', 'users:list'),
+    ('admin', 'users:write'),
+    ('admin', 'users:delete'),
+    ('admin', 'users:manage_roles'),
+    ('admin', 'orders:read'),
+    ('admin', 'orders:write'),
+    ('admin', 'orders:delete')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+    ADD CONSTRAINT fk_users_role FOREIGN KEY(role) REFERENCES roles(name);
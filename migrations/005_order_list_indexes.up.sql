@@ -0,0 +1,7 @@
+-- История заказов пользователя с keyset пагинацией. idx_orders_user_id остаётся:
+-- он меньше и им пользуются остальные запросы по user_id
+CREATE INDEX IF NOT EXISTS idx_orders_user_id_created_at_id ON orders(user_id, created_at DESC, id DESC);
+
+-- Общий список заказов без фильтра по пользователю сортируется по дате или сумме
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_total_id ON orders(total, id);
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'orders:status';
+DROP TABLE IF EXISTS order_status_history;
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
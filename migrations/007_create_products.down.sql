@@ -0,0 +1,5 @@
+DELETE FROM role_permissions WHERE permission = 'products:write';
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS fk_product;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_id;
+DROP TRIGGER IF EXISTS update_products_updated_at ON products;
+DROP TABLE IF EXISTS products;
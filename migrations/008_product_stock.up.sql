@@ -0,0 +1,3 @@
+-- Остаток на складе, резерв списывается при оформлении заказа
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0);
@@ -0,0 +1,2 @@
+-- Расширение не удаляем: им могут пользоваться и другие схемы
+DROP INDEX IF EXISTS idx_order_items_name_trgm;
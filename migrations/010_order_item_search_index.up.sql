@@ -0,0 +1,18 @@
+-- Базы, на которых 005 успела удалить одиночный индекс по user_id
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
+
+-- Поиск предметов по подстроке (ILIKE '%...%') без полного прохода по order_items.
+-- pg_trgm необязателен: без прав на CREATE EXTENSION (managed Postgres) поиск работает, только медленнее
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+    RAISE NOTICE 'pg_trgm is unavailable, order item search runs without trigram index';
+END $$;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_order_items_name_trgm ON order_items USING GIN (name gin_trgm_ops);
+    END IF;
+END $$;